@@ -2,89 +2,176 @@ package bot
 
 import (
 	"contest-registration-bot/storage"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// contestLinkPrefix Prefix of /start payload in contest registration links
+	contestLinkPrefix = "c_"
+
+	callbackChooseContest = "choose_contest"
 )
 
 var chooseContestSteps = map[string]DialogAction{
 	ChooseContestStepZero: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
-		contests, err := storage.GetContests()
-		if err != nil {
-			log.Errorf("choose contest: unable to get contests: %s", err)
-			return true, bot.msg(update, esc("Не удалось найти контесты :("))
-		}
-
-		var contestButtons []tgbotapi.KeyboardButton
+		return bot.askContest(update, state)
+	},
 
-		for _, contest := range contests {
-			if contest.Hidden || contest.Closed {
-				continue
+	ChooseContestStepChoice: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		if update.CallbackQuery == nil {
+			//dialogs started before contest buttons became inline still show reply keyboard
+			//with contest names, it is removed and inline buttons are sent again
+			if err := bot.msgWithReplyMarkup(update, esc(bot.t(update, "bot.choose.press_button")), tgbotapi.NewRemoveKeyboard(false)); err != nil {
+				return false, err
 			}
-			button := tgbotapi.NewKeyboardButton(contest.Name)
-			contestButtons = append(contestButtons, button)
-		}
-
-		if len(contestButtons) == 0 {
-			return true, bot.msg(update, "Доступных для регистрации контестов нет")
+			return bot.askContest(update, state)
 		}
 
-		message := tgbotapi.NewMessage(update.Message.Chat.ID, "Выберите доступный для регистрации контест.\nНажмите на кнопку с названием контеста")
-		keyboard := tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(contestButtons...))
-		keyboard.OneTimeKeyboard = true
-		message.ReplyMarkup = keyboard
-		_, err = bot.api.Send(message)
-
-		state.DialogStep = ChooseContestStepChoice
-
-		return false, err
-	},
-
-	ChooseContestStepChoice: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
-		message := tgbotapi.NewMessage(update.Message.Chat.ID, "...")
-		message.ReplyMarkup = tgbotapi.NewRemoveKeyboard(false)
-		_, err := bot.api.Send(message)
-		if err != nil {
-			log.Errorf("choose contest: unable to remove keyboard: %s", err)
-			return true, bot.msg(update, esc("Что-то пошло не так :("))
+		action, contestId := parseCallbackData(update.CallbackQuery.Data)
+		if action != callbackChooseContest {
+			return false, nil
 		}
 
-		contest, err := storage.GetContestByName(update.Message.Text)
+		contest, err := bot.chosenContest(update, contestId)
 		if err != nil {
-			log.Errorf("choose contest: %s", err)
-			return true, bot.msg(update, esc("Не удалось найти контест с указанным именем :("))
+			return true, err
 		}
 		if contest == nil {
-			log.Errorf("choose contest: %s", err)
-			return true, bot.msg(update, esc("Контест не найден :("))
+			return true, nil
 		}
-		if contest.Hidden {
-			log.Errorf("choose contest: request of hidden contest %d", contest.Id)
-			return true, bot.msg(update, esc("Этот контест больше не существует :("))
+
+		return bot.startRegistration(update, state, contest)
+	},
+}
+
+// askContest Send inline buttons of contests open for registration
+func (bot *Bot) askContest(update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+	contests, err := storage.GetContests()
+	if err != nil {
+		log.Errorf("choose contest: unable to get contests: %s", err)
+		return true, bot.msg(update, esc(bot.t(update, "bot.errors.contests")))
+	}
+	storage.SortContestsByStart(contests)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	for _, contest := range contests {
+		if contest.Hidden || !contest.RegistrationOpen(time.Now()) {
+			continue
 		}
-		if contest.Closed {
-			log.Errorf("choose contest: request of closed contest %d", contest.Id)
-			return true, bot.msg(update, esc("Регистрация на этот контест закрыта :("))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(contest.Name, callbackData(callbackChooseContest, contest.Id)),
+		))
+	}
+
+	if len(rows) == 0 {
+		return true, bot.msg(update, esc(bot.t(update, "bot.choose.none")))
+	}
+
+	state.DialogStep = ChooseContestStepChoice
+
+	message := esc(bot.t(update, "bot.choose.prompt"))
+	return false, bot.msgWithKeyboard(update, message, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// chosenContest Contest of pressed inline button, message with the keyboard is replaced with contest name
+func (bot *Bot) chosenContest(update *tgbotapi.Update, contestId uint64) (*storage.Contest, error) {
+	contest, err := storage.GetContest(contestId)
+	if err != nil {
+		log.Errorf("choose contest: unable to get contest %d: %s", contestId, err)
+		return nil, bot.editMsg(update, esc(bot.t(update, "bot.choose.not_found")))
+	}
+	if err := bot.editMsg(update, "*"+esc(contest.Name)+"*"); err != nil {
+		log.Errorf("choose contest: unable to edit message: %s", err)
+	}
+	return contest, nil
+}
+
+// ContestLink Deep link starting registration to the contest
+func (bot *Bot) ContestLink(contestId uint64) string {
+	return bot.startLink(fmt.Sprintf("%s%d", contestLinkPrefix, contestId))
+}
+
+// startContestRegistration Start registration to the contest from deep link
+func (bot *Bot) startContestRegistration(update *tgbotapi.Update, payload string) error {
+	contestId, err := strconv.ParseUint(payload, 10, 64)
+	if err != nil {
+		return bot.msg(update, esc(bot.t(update, "bot.choose.not_found")))
+	}
+	contest, err := storage.GetContest(contestId)
+	if err != nil {
+		log.Warnf("contest link: contest %d not found: %s", contestId, err)
+		return bot.msg(update, esc(bot.t(update, "bot.choose.not_found")))
+	}
+	return bot.startContestDialog(update, contest)
+}
+
+// callbackChooseContest Contest button pressed after choose contest dialog was finished or canceled
+func (bot *Bot) callbackChooseContest(update *tgbotapi.Update, contestId uint64) error {
+	contest, err := bot.chosenContest(update, contestId)
+	if err != nil || contest == nil {
+		return err
+	}
+	return bot.startContestDialog(update, contest)
+}
+
+// startContestDialog Start registration dialog to the contest outside of choose contest dialog
+func (bot *Bot) startContestDialog(update *tgbotapi.Update, contest *storage.Contest) error {
+	state := &storage.DialogState{
+		ParticipantId: update.FromChat().ID,
+		DialogType:    DialogTypeChooseContest,
+		DialogStep:    ChooseContestStepChoice,
+	}
+	_, err := bot.startRegistration(update, state, contest)
+	return err
+}
+
+// startRegistration Check that participant can register to the contest and start registration dialog
+func (bot *Bot) startRegistration(update *tgbotapi.Update, state *storage.DialogState, contest *storage.Contest) (bool, error) {
+	if contest.Hidden {
+		log.Errorf("choose contest: request of hidden contest %d", contest.Id)
+		return true, bot.msg(update, esc(bot.t(update, "bot.choose.hidden")))
+	}
+	if !contest.RegistrationOpen(time.Now()) {
+		log.Errorf("choose contest: request of closed contest %d", contest.Id)
+		return true, bot.msg(update, esc(bot.t(update, "bot.choose.closed")))
+	}
+
+	participantId := state.ParticipantId
+	participation, err := storage.GetContestParticipantParticipation(participantId)
+	if err != nil {
+		log.Errorf("choose contest: unable to find participant's contests: %s", err)
+		return true, bot.msg(update, esc(bot.t(update, "bot.errors.generic")))
+	}
+	for _, participant := range participation {
+		if participant.ContestId == contest.Id {
+			log.Infof("choose contest: double registration of %d to %d", participantId, contest.Id)
+			return true, bot.msg(update, esc(bot.t(update, "bot.choose.duplicate")))
 		}
+	}
 
-		participantId := update.Message.Chat.ID
-		participation, err := storage.GetContestParticipantParticipation(participantId)
+	if contest.Capacity > 0 {
+		registered, err := storage.CountRegisteredParticipants(contest.Id)
 		if err != nil {
-			log.Errorf("choose contest: unable to find participant's contests: %s", err)
-			return true, bot.msg(update, esc("Что-то пошло не так :("))
+			log.Errorf("choose contest: unable to count participants of %d: %s", contest.Id, err)
+			return true, bot.msg(update, esc(bot.t(update, "bot.errors.generic")))
 		}
-		for _, participant := range participation {
-			if participant.ContestId == contest.Id {
-				log.Infof("choose contest: double registration of %d to %d", participantId, contest.Id)
-				return true, bot.msg(update, "На этот контест уже есть регистрация")
+		if registered >= contest.Capacity {
+			if err := bot.msg(update, esc(bot.t(update, "bot.choose.full"))); err != nil {
+				return true, err
 			}
 		}
+	}
 
-		state.DialogType = DialogTypeRegistration
-		state.DialogStep = RegistrationStepZero
-		state.Values = storage.DialogValues{
-			"ContestId": contest.Id,
-		}
+	state.DialogType = DialogTypeRegistration
+	state.DialogStep = RegistrationStepZero
+	state.Values = storage.DialogValues{
+		registrationValueContestId: contest.Id,
+	}
 
-		return false, bot.processDialog(update, state)
-	},
+	return false, bot.processDialog(update, state)
 }
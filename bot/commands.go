@@ -1,15 +1,17 @@
 package bot
 
 import (
+	"contest-registration-bot/i18n"
 	"contest-registration-bot/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	log "github.com/sirupsen/logrus"
 	"strings"
+	"time"
 )
 
 func (bot *Bot) processCommand(update *tgbotapi.Update) error {
 	if !update.Message.IsCommand() {
-		return bot.msg(update, esc("Пожалуйста, введите команду. Для справки введите /help"))
+		return bot.msg(update, esc(bot.t(update, "bot.not_command")))
 	}
 
 	switch update.Message.Command() {
@@ -21,38 +23,56 @@ func (bot *Bot) processCommand(update *tgbotapi.Update) error {
 		return bot.commandContests(update)
 	case "registration":
 		return bot.commandRegistration(update)
+	case "myregistrations":
+		return bot.commandMyRegistrations(update)
+	case "join":
+		return bot.commandJoin(update)
+	case "language":
+		return bot.commandLanguage(update)
+	case "forgetme":
+		return bot.commandForgetMe(update)
 	default:
-		return bot.msg(update, esc("Не знаю такой команды :("))
+		return bot.msg(update, esc(bot.t(update, "bot.unknown_command")))
 	}
 }
 
-// commandStart First run message
+// commandStart First run message, contest links and team invite links start the bot with payload
 func (bot *Bot) commandStart(update *tgbotapi.Update) error {
-	return bot.msg(update, esc("Этот бот поможет зарегистрироваться на олимпиаду. Для справки введите /help"))
+	payload := update.Message.CommandArguments()
+	if code, ok := strings.CutPrefix(payload, teamInvitePrefix); ok {
+		return bot.startJoinTeam(update, code)
+	}
+	if contestId, ok := strings.CutPrefix(payload, contestLinkPrefix); ok {
+		return bot.startContestRegistration(update, contestId)
+	}
+	return bot.msg(update, esc(bot.t(update, "bot.start")))
 }
 
 // commandHelp Help message
 func (bot *Bot) commandHelp(update *tgbotapi.Update) error {
+	lang := bot.language(update)
 	message := strings.Builder{}
-	message.WriteString(esc("Этот бот поможет зарегистрироваться на контест. Доступные команды:\n"))
-	message.WriteString(esc("/help - справка\n"))
-	message.WriteString(esc("/contests - список контестов и сведения о регистрации\n"))
-	message.WriteString(esc("/registration - регистрация на контест\n"))
+	for _, key := range []string{"title", "help", "contests", "registration", "my_registrations", "join", "language", "forgetme"} {
+		message.WriteString(esc(i18n.T(lang, "bot.help."+key)) + "\n")
+	}
 	return bot.msg(update, message.String())
 }
 
 // commandContests List all current contests
 func (bot *Bot) commandContests(update *tgbotapi.Update) error {
+	lang := bot.language(update)
+
 	contests, err := storage.GetContests()
 	if err != nil {
 		log.Errorf("/contests: unable to get contests: %s", err)
-		return bot.msg(update, esc("Не удалось найти контесты :("))
+		return bot.msg(update, esc(i18n.T(lang, "bot.errors.contests")))
 	}
+	storage.SortContestsByStart(contests)
 
 	participation, err := storage.GetContestParticipantParticipation(update.Message.Chat.ID)
 	if err != nil {
 		log.Errorf("/contests: unable to get participation: %s", err)
-		return bot.msg(update, esc("Не удалось найти регистрации на контесты :("))
+		return bot.msg(update, esc(i18n.T(lang, "bot.errors.participation")))
 	}
 	participants := make(map[uint64]storage.ContestParticipant)
 	for _, participant := range participation {
@@ -60,7 +80,7 @@ func (bot *Bot) commandContests(update *tgbotapi.Update) error {
 	}
 
 	message := strings.Builder{}
-	message.WriteString("Найдены контесты:\n")
+	message.WriteString(esc(i18n.T(lang, "bot.contests.found")) + "\n")
 
 	contestsFound := false
 
@@ -73,35 +93,65 @@ func (bot *Bot) commandContests(update *tgbotapi.Update) error {
 
 		message.WriteRune('\n')
 		message.WriteString("*" + esc(contest.Name) + "*\n")
-		message.WriteString("*Что:* " + esc(contest.Description) + "\n")
-		message.WriteString("*Где:* " + esc(contest.Where) + "\n")
-		message.WriteString("*Когда:* " + esc(contest.When) + "\n")
-		if contest.Closed {
-			message.WriteString("_Регистрация на контест закрыта_\n")
+		message.WriteString(labeled(i18n.T(lang, "bot.labels.what"), esc(contest.Description)))
+		message.WriteString(labeled(i18n.T(lang, "bot.labels.where"), esc(contest.Where)))
+		when := contestPeriod(lang, &contest)
+		if len(contest.Note) != 0 {
+			if len(when) != 0 {
+				when += "\n"
+			}
+			when += contest.Note
+		}
+		if len(when) != 0 {
+			message.WriteString(labeled(i18n.T(lang, "bot.labels.when"), esc(when)))
+		}
+		if !contest.RegistrationOpen(time.Now()) {
+			message.WriteString(italic(i18n.T(lang, "bot.contests.closed")) + "\n")
+		} else if !contest.RegistrationDeadline.IsZero() {
+			deadline := formatDateTime(lang, contest.InLocation(contest.RegistrationDeadline))
+			message.WriteString(labeled(i18n.T(lang, "bot.labels.deadline"), esc(deadline)))
 		}
 
 		participant, ok := participants[contest.Id]
 		if ok {
-			message.WriteString("_Есть регистрация на контест_\n")
-			message.WriteString("*Имя:* " + esc(participant.Name) + "\n")
-			message.WriteString("*Школа/ВУЗ:* " + esc(participant.School) + "\n")
-			message.WriteString("*Логин:* `" + esc(participant.Login) + "`\n")
-			message.WriteString("*Пароль:* `" + esc(participant.Password) + "`\n")
+			message.WriteString(italic(i18n.T(lang, "bot.contests.registered")) + "\n")
+			for _, field := range contest.FormFields() {
+				answer := participant.Answer(field.Key)
+				if len(answer) == 0 {
+					continue
+				}
+				message.WriteString("*" + esc(field.Title) + ":* " + esc(answer) + "\n")
+			}
+			if contest.TeamMode {
+				message.WriteString(teamMembers(lang, &participant))
+				if participant.ParticipantId == update.Message.Chat.ID {
+					message.WriteString(labeled(i18n.T(lang, "bot.labels.invite_code"), "`"+esc(participant.InviteCode)+"`"))
+				}
+			}
+			if participant.Waitlisted {
+				position, err := storage.GetWaitlistPosition(&participant)
+				if err != nil {
+					log.Errorf("/contests: unable to get waitlist position of %d: %s", participant.Id, err)
+				}
+				message.WriteString(italic(i18n.T(lang, "bot.contests.waitlisted", position)) + "\n")
+			} else {
+				message.WriteString(credentials(lang, &participant))
+			}
 
-			notifications, err := storage.GetContestNotifications(contest.Id)
+			notifications, err := storage.GetSentContestNotifications(contest.Id)
 			if err != nil {
 				log.Errorf("/contest: unable to get notifications of contest %d: %s", contest.Id, err)
 			} else if len(notifications) > 0 {
-				message.WriteString("_Оповещения участников:_\n")
+				message.WriteString(italic(i18n.T(lang, "bot.contests.notifications")) + "\n")
 				for _, notification := range notifications {
-					message.WriteString(esc(">>> "+notification.Message) + "\n")
+					message.WriteString(esc(">>> "+NotificationText(lang, &contest, &notification)) + "\n")
 				}
 			}
 		}
 	}
 
 	if !contestsFound {
-		return bot.msg(update, "Сейчас контестов нет")
+		return bot.msg(update, esc(i18n.T(lang, "bot.contests.none")))
 	}
 
 	return bot.msg(update, message.String())
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"contest-registration-bot/i18n"
+	"contest-registration-bot/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+const callbackLanguage = "language"
+
+// t Message in the language of update's chat
+func (bot *Bot) t(update *tgbotapi.Update, key string, args ...interface{}) string {
+	return i18n.T(bot.language(update), key, args...)
+}
+
+// language Language of update's chat
+func (bot *Bot) language(update *tgbotapi.Update) string {
+	return chatLanguage(update.FromChat().ID)
+}
+
+// chatLanguage Language chosen with /language or language of participant's Telegram client
+func chatLanguage(chatId int64) string {
+	settings, err := storage.GetChatSettings(chatId)
+	if err != nil {
+		log.Errorf("unable to get settings of chat %d: %s", chatId, err)
+		return i18n.DefaultLanguage
+	}
+	if len(settings.Language) != 0 {
+		return settings.Language
+	}
+	return i18n.Language(settings.ClientLanguage)
+}
+
+// rememberClientLanguage Save Telegram client language to use it in messages sent outside of updates
+func rememberClientLanguage(update *tgbotapi.Update) {
+	user := update.SentFrom()
+	if user == nil {
+		return
+	}
+	chatId := update.FromChat().ID
+	settings, err := storage.GetChatSettings(chatId)
+	if err != nil {
+		log.Errorf("unable to get settings of chat %d: %s", chatId, err)
+		return
+	}
+	if settings.ClientLanguage == user.LanguageCode {
+		return
+	}
+	settings.ClientLanguage = user.LanguageCode
+	if err := storage.SaveChatSettings(settings); err != nil {
+		log.Errorf("unable to save settings of chat %d: %s", chatId, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// commandLanguage Choose bot language, "/language en" sets it right away
+func (bot *Bot) commandLanguage(update *tgbotapi.Update) error {
+	lang := strings.ToLower(strings.TrimSpace(update.Message.CommandArguments()))
+	if len(lang) != 0 {
+		if !i18n.Supported(lang) {
+			return bot.msg(update, esc(bot.t(update, "bot.language.unknown", strings.Join(i18n.Languages(), ", "))))
+		}
+		if err := saveChatLanguage(update.FromChat().ID, lang); err != nil {
+			return bot.msg(update, esc(bot.t(update, "bot.errors.failed")))
+		}
+		return bot.msg(update, esc(bot.t(update, "bot.language.changed")))
+	}
+
+	//button id is language number starting from 1, 0 is Telegram client language
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, lang := range i18n.Languages() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "language.name"), callbackData(callbackLanguage, uint64(i+1))),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(bot.t(update, "bot.language.auto"), callbackData(callbackLanguage, 0)),
+	))
+
+	return bot.msgWithKeyboard(update, esc(bot.t(update, "bot.language.prompt")), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// callbackLanguage Save language chosen with /language keyboard
+func (bot *Bot) callbackLanguage(update *tgbotapi.Update, number uint64) error {
+	languages := i18n.Languages()
+	if number > uint64(len(languages)) {
+		return nil
+	}
+	lang := ""
+	if number != 0 {
+		lang = languages[number-1]
+	}
+	if err := saveChatLanguage(update.FromChat().ID, lang); err != nil {
+		return bot.msg(update, esc(bot.t(update, "bot.errors.failed")))
+	}
+	return bot.editMsg(update, esc(bot.t(update, "bot.language.changed")))
+}
+
+// saveChatLanguage Save language of the chat, empty language resets it to Telegram client language
+func saveChatLanguage(chatId int64, lang string) error {
+	settings, err := storage.GetChatSettings(chatId)
+	if err != nil {
+		log.Errorf("unable to get settings of chat %d: %s", chatId, err)
+		return err
+	}
+	settings.Language = lang
+	if err := storage.SaveChatSettings(settings); err != nil {
+		log.Errorf("unable to save settings of chat %d: %s", chatId, err)
+		return err
+	}
+	return nil
+}
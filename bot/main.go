@@ -1,38 +1,67 @@
 package bot
 
 import (
+	"contest-registration-bot/i18n"
 	"contest-registration-bot/storage"
 	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	log "github.com/sirupsen/logrus"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	defaultTimeout = 30
+	defaultTimeout     = 30
+	defaultWebhookPath = "/telegram/webhook"
 
-	DialogTypeRegistration  = "registration"
-	DialogTypeChooseContest = "choose_contest"
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
 
-	RegistrationStepZero      = "zero"
-	RegistrationStepName      = "name"
-	RegistrationStepSchool    = "school"
-	RegistrationStepContacts  = "contacts"
-	RegistrationStepLanguages = "languages"
+	DialogTypeRegistration     = "registration"
+	DialogTypeChooseContest    = "choose_contest"
+	DialogTypeEditRegistration = "edit_registration"
+	DialogTypeJoinTeam         = "join_team"
+
+	RegistrationStepZero        = "zero"
+	RegistrationStepConsent     = "consent"
+	RegistrationStepField       = "field"
+	RegistrationStepCaptainName = "captain_name"
+	RegistrationStepSummary     = "summary"
 
 	ChooseContestStepZero   = "zero"
 	ChooseContestStepChoice = "choice"
+
+	EditRegistrationStepZero   = "zero"
+	EditRegistrationStepChoice = "choice"
+	EditRegistrationStepValue  = "value"
+
+	JoinTeamStepZero    = "zero"
+	JoinTeamStepConsent = "consent"
+	JoinTeamStepName    = "name"
 )
 
 type Configuration struct {
 	Token         string
 	Debug         bool
 	UpdateTimeout int
+	Mode          string
+	WebhookURL    string
+	WebhookPath   string
+	WebhookSecret string
+	// ConsentPolicy Personal data policy accepted before registration, consent step is skipped without policy and its URL
+	ConsentPolicy        string
+	ConsentPolicyURL     string
+	ConsentPolicyVersion string
 }
 
 type Bot struct {
-	api    *tgbotapi.BotAPI
-	config Configuration
+	api           *tgbotapi.BotAPI
+	config        Configuration
+	updates       chan tgbotapi.Update
+	deliveryMutex sync.Mutex
 }
 
 type DialogAction func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error)
@@ -42,8 +71,10 @@ var dialogs map[string]DialogSteps
 
 func init() {
 	dialogs = map[string]DialogSteps{
-		DialogTypeRegistration:  registrationSteps,
-		DialogTypeChooseContest: chooseContestSteps,
+		DialogTypeRegistration:     registrationSteps,
+		DialogTypeChooseContest:    chooseContestSteps,
+		DialogTypeEditRegistration: editRegistrationSteps,
+		DialogTypeJoinTeam:         joinTeamSteps,
 	}
 }
 
@@ -57,6 +88,18 @@ func New(config Configuration) (*Bot, error) {
 	if config.UpdateTimeout == 0 {
 		config.UpdateTimeout = defaultTimeout
 	}
+	if config.Mode == "" {
+		config.Mode = ModePolling
+	}
+	switch config.Mode {
+	case ModePolling:
+	case ModeWebhook:
+		if err := checkWebhookConfiguration(&config); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unknown bot mode: " + config.Mode)
+	}
 
 	api, err := tgbotapi.NewBotAPI(config.Token)
 	if err != nil {
@@ -66,17 +109,32 @@ func New(config Configuration) (*Bot, error) {
 	api.Debug = config.Debug
 
 	return &Bot{
-		api:    api,
-		config: config,
+		api:     api,
+		config:  config,
+		updates: make(chan tgbotapi.Update, api.Buffer),
 	}, nil
 }
 
 // Start Process new messages
-func (bot *Bot) Start() {
-	updateConfig := tgbotapi.NewUpdate(0)
-	updateConfig.Timeout = bot.config.UpdateTimeout
+func (bot *Bot) Start() error {
+	var updates tgbotapi.UpdatesChannel
+
+	if bot.WebhookEnabled() {
+		if err := bot.setWebhook(); err != nil {
+			return err
+		}
+		updates = bot.updates
+	} else {
+		//webhook left from previous runs blocks getUpdates
+		if _, err := bot.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			return err
+		}
+		updateConfig := tgbotapi.NewUpdate(0)
+		updateConfig.Timeout = bot.config.UpdateTimeout
+		updates = bot.api.GetUpdatesChan(updateConfig)
+	}
 
-	updates := bot.api.GetUpdatesChan(updateConfig)
+	go bot.resumeNotificationDeliveries()
 
 	go func() {
 		for update := range updates {
@@ -85,55 +143,81 @@ func (bot *Bot) Start() {
 			}
 		}
 	}()
+
+	return nil
 }
 
-// SendNotifications Send notifications to all participants of the contest
-func (bot *Bot) SendNotifications(contestId uint64, text string) error {
-	contest, err := storage.GetContest(contestId)
-	if err != nil {
-		return err
+// Stop Stop receiving new messages
+func (bot *Bot) Stop() {
+	if bot.WebhookEnabled() {
+		if err := bot.deleteWebhook(); err != nil {
+			log.Errorf("unable to delete webhook: %s", err)
+		}
+	} else {
+		bot.api.StopReceivingUpdates()
 	}
+}
 
-	participants, err := storage.GetContestParticipants(contestId)
-	if err != nil {
-		return nil
-	}
+// NotifyPromoted Send credentials to participants moved from the waitlist
+func (bot *Bot) NotifyPromoted(participants []storage.ContestParticipant) {
+	for _, participant := range participants {
+		if len(participant.ChatIds()) == 0 {
+			continue
+		}
 
-	messageBuilder := strings.Builder{}
-	messageBuilder.WriteString("*Оповещение участников контеста \"" + esc(contest.Name) + "\"*:\n\n")
-	messageBuilder.WriteString(esc(text))
-	messageText := messageBuilder.String()
+		contest, err := storage.GetContest(participant.ContestId)
+		if err != nil {
+			log.Errorf("unable to get contest %d: %s", participant.ContestId, err)
+			continue
+		}
 
-	go func() {
-		for _, participant := range participants {
-			if participant.ParticipantId == 0 {
-				continue
+		for _, chatId := range participant.ChatIds() {
+			lang := chatLanguage(chatId)
+			messageBuilder := strings.Builder{}
+			messageBuilder.WriteString(bold(i18n.T(lang, "bot.promoted.title", contest.Name)) + "\n\n")
+			if contest.TeamMode {
+				messageBuilder.WriteString(esc(i18n.T(lang, "bot.promoted.team", participant.Name)) + "\n")
+			} else {
+				messageBuilder.WriteString(esc(i18n.T(lang, "bot.promoted.participant")) + "\n")
 			}
-			message := tgbotapi.NewMessage(participant.ParticipantId, messageText)
-			message.ParseMode = tgbotapi.ModeMarkdownV2
-			_, err := bot.api.Send(message)
-			if err != nil {
-				log.Errorf("unable to send contest %d notification to %d", contestId, participant.ParticipantId)
+			messageBuilder.WriteString(credentials(lang, &participant))
+
+			if err := bot.send(chatId, messageBuilder.String()); err != nil {
+				log.Errorf("unable to send promotion message of contest %d to %d: %s", participant.ContestId, chatId, err)
 			}
 		}
-	}()
-
-	return nil
+	}
 }
 
 func (bot *Bot) processUpdate(update *tgbotapi.Update) error {
-	if update.Message == nil {
+	if update.CallbackQuery != nil {
+		if update.CallbackQuery.Message == nil {
+			return nil
+		}
+		defer bot.answerCallback(update)
+	} else if update.Message == nil {
 		return nil
 	}
 
-	participantChatId := update.Message.Chat.ID
+	participantChatId := update.FromChat().ID
+	rememberClientLanguage(update)
 
 	dialogState, err := storage.GetDialogState(participantChatId)
 	if err != nil {
 		return err
 	}
+	//contest and invite links open what they point to instead of answering the current question
+	if dialogState != nil && isStartLink(update.Message) {
+		if err := storage.DeleteDialogState(participantChatId); err != nil {
+			log.Errorf("unable to delete dialog state %d: %s", participantChatId, err)
+			return bot.msg(update, esc(bot.t(update, "bot.errors.failed")))
+		}
+		return bot.commandStart(update)
+	}
 	if dialogState != nil {
 		return bot.processDialog(update, dialogState)
+	} else if update.CallbackQuery != nil {
+		return bot.processCallback(update)
 	} else {
 		return bot.processCommand(update)
 	}
@@ -145,8 +229,8 @@ func (bot *Bot) processDialog(update *tgbotapi.Update, dialogState *storage.Dial
 		log.Errorf("found unknown dialog type: %s", dialogState.DialogType)
 		if err := storage.DeleteDialogState(dialogState.ParticipantId); err != nil {
 			log.Errorf("unable to delete dialog state: %d: %s", dialogState.ParticipantId, err)
-			return bot.msg(update, esc("Произошла ошибка :( Попробуйте еще раз"))
 		}
+		return bot.msg(update, esc(bot.t(update, "bot.errors.dialog")))
 	}
 
 	dialogAction, ok := dialogSteps[dialogState.DialogStep]
@@ -154,16 +238,17 @@ func (bot *Bot) processDialog(update *tgbotapi.Update, dialogState *storage.Dial
 		log.Errorf("found unknown dialog step: %s.%s", dialogState.DialogType, dialogState.DialogStep)
 		if err := storage.DeleteDialogState(dialogState.ParticipantId); err != nil {
 			log.Errorf("unable to delete dialog state: %d: %s", dialogState.ParticipantId, err)
-			return bot.msg(update, esc("Произошла ошибка :( Попробуйте еще раз"))
 		}
+		return bot.msg(update, esc(bot.t(update, "bot.errors.dialog")))
 	}
 
-	if update.Message.Text == "/cancel" {
+	if update.Message != nil && update.Message.Text == "/cancel" {
 		if err := storage.DeleteDialogState(dialogState.ParticipantId); err != nil {
 			log.Errorf("unable to delete dialog state %d: %s", dialogState.ParticipantId, err)
-			return bot.msg(update, esc("Произошла ошибка :("))
+			return bot.msg(update, esc(bot.t(update, "bot.errors.failed")))
 		} else {
-			return bot.msg(update, esc("Отменено"))
+			//contact sharing keyboard may be left from the dialog
+			return bot.msgWithReplyMarkup(update, esc(bot.t(update, "bot.canceled")), tgbotapi.NewRemoveKeyboard(false))
 		}
 	}
 
@@ -175,33 +260,128 @@ func (bot *Bot) processDialog(update *tgbotapi.Update, dialogState *storage.Dial
 	if done {
 		if err := storage.DeleteDialogState(dialogState.ParticipantId); err != nil {
 			log.Errorf("unable to delete dialog state %d: %s", dialogState.ParticipantId, err)
-			return bot.msg(update, esc("Произошла ошибка :("))
+			return bot.msg(update, esc(bot.t(update, "bot.errors.failed")))
 		}
 	} else {
 		if err := storage.SaveDialogState(dialogState); err != nil {
 			log.Errorf("unable to sage dialog state %d: %s", dialogState.ParticipantId, err)
-			return bot.msg(update, esc("Не удалось сохранить данные :(\nПопробуйте еще раз"))
+			return bot.msg(update, esc(bot.t(update, "bot.errors.save_state")))
 		}
 	}
 
 	return nil
 }
 
+// isStartLink Message is /start with contest or team invite payload
+func isStartLink(message *tgbotapi.Message) bool {
+	if message == nil || !message.IsCommand() || message.Command() != "start" {
+		return false
+	}
+	payload := message.CommandArguments()
+	return strings.HasPrefix(payload, contestLinkPrefix) || strings.HasPrefix(payload, teamInvitePrefix)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Utility methods
 
 // msg Set plain text message to update's channel
 func (bot *Bot) msg(update *tgbotapi.Update, message string) error {
-	response := tgbotapi.NewMessage(update.Message.Chat.ID, message)
+	response := tgbotapi.NewMessage(update.FromChat().ID, message)
+	response.ParseMode = tgbotapi.ModeMarkdownV2
+	_, err := bot.api.Send(response)
+	return err
+}
+
+// send Send message to given chat outside of update processing
+func (bot *Bot) send(chatId int64, message string) error {
+	response := tgbotapi.NewMessage(chatId, message)
+	response.ParseMode = tgbotapi.ModeMarkdownV2
+	_, err := bot.api.Send(response)
+	return err
+}
+
+// startLink Link opening the bot with /start payload
+func (bot *Bot) startLink(payload string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", bot.api.Self.UserName, payload)
+}
+
+// msgWithKeyboard Send message with inline keyboard to update's channel
+func (bot *Bot) msgWithKeyboard(update *tgbotapi.Update, message string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	response := tgbotapi.NewMessage(update.FromChat().ID, message)
+	response.ParseMode = tgbotapi.ModeMarkdownV2
+	response.ReplyMarkup = keyboard
+	_, err := bot.api.Send(response)
+	return err
+}
+
+// msgWithReplyMarkup Send message with reply keyboard or its removal to update's channel
+func (bot *Bot) msgWithReplyMarkup(update *tgbotapi.Update, message string, markup interface{}) error {
+	response := tgbotapi.NewMessage(update.FromChat().ID, message)
+	response.ParseMode = tgbotapi.ModeMarkdownV2
+	response.ReplyMarkup = markup
+	_, err := bot.api.Send(response)
+	return err
+}
+
+// editMsg Replace text of the message with pressed inline button, its keyboard is removed
+func (bot *Bot) editMsg(update *tgbotapi.Update, message string) error {
+	original := update.CallbackQuery.Message
+	response := tgbotapi.NewEditMessageText(original.Chat.ID, original.MessageID, message)
 	response.ParseMode = tgbotapi.ModeMarkdownV2
 	_, err := bot.api.Send(response)
 	return err
 }
 
+// answerCallback Confirm callback query processing to stop client's progress indicator
+func (bot *Bot) answerCallback(update *tgbotapi.Update) {
+	if _, err := bot.api.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, "")); err != nil {
+		log.Errorf("unable to answer callback query: %s", err)
+	}
+}
+
+// callbackData Inline button data with action and entity id
+func callbackData(action string, id uint64) string {
+	return fmt.Sprintf("%s:%d", action, id)
+}
+
+// parseCallbackData Action and entity id from inline button data
+func parseCallbackData(data string) (string, uint64) {
+	action, idStr, found := strings.Cut(data, ":")
+	if !found {
+		return action, 0
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return action, 0
+	}
+	return action, id
+}
+
 func esc(text string) string {
 	return tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, text)
 }
 
+// bold Escaped bold text
+func bold(text string) string {
+	return "*" + esc(text) + "*"
+}
+
+// italic Escaped italic text
+func italic(text string) string {
+	return "_" + esc(text) + "_"
+}
+
+// labeled Line with bold label and value already escaped
+func labeled(label, value string) string {
+	return "*" + esc(label) + ":* " + value + "\n"
+}
+
+// credentials Login and password lines
+func credentials(lang string, participant *storage.ContestParticipant) string {
+	return labeled(i18n.T(lang, "bot.labels.login"), "`"+esc(participant.Login)+"`") +
+		labeled(i18n.T(lang, "bot.labels.password"), "`"+esc(participant.Password)+"`")
+}
+
 func trim(text string, maxLength int) string {
 	trimmed := strings.TrimSpace(text)
 	runes := []rune(trimmed)
@@ -216,3 +396,34 @@ func min(a, b int) int {
 		return b
 	}
 }
+
+// formatDateTime Human-readable date and time, e.g. "18 октября 2026, 10:00"
+func formatDateTime(lang string, t time.Time) string {
+	months := strings.Split(i18n.T(lang, "bot.months"), ",")
+	return i18n.T(lang, "bot.date_time", t.Day(), months[t.Month()-1], t.Year(), t.Format("15:04"))
+}
+
+// contestPeriod Contest start and end time in contest timezone
+func contestPeriod(lang string, contest *storage.Contest) string {
+	if contest.StartsAt.IsZero() {
+		return ""
+	}
+
+	startsAt := contest.InLocation(contest.StartsAt)
+	period := formatDateTime(lang, startsAt)
+
+	if !contest.EndsAt.IsZero() {
+		endsAt := contest.InLocation(contest.EndsAt)
+		if endsAt.Year() == startsAt.Year() && endsAt.YearDay() == startsAt.YearDay() {
+			period += " — " + endsAt.Format("15:04")
+		} else {
+			period += " — " + formatDateTime(lang, endsAt)
+		}
+	}
+
+	if len(contest.Timezone) != 0 {
+		period += " (" + contest.Timezone + ")"
+	}
+
+	return period
+}
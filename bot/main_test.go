@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"contest-registration-bot/i18n"
+	"contest-registration-bot/storage"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramRequest Bot API method called by the bot with its parameters
+type telegramRequest struct {
+	Method string
+	Params url.Values
+}
+
+// fakeTelegram Local stand-in of Telegram Bot API accepting every request
+type fakeTelegram struct {
+	mutex    sync.Mutex
+	requests []telegramRequest
+}
+
+// newTestBot Open empty storage and create bot talking to local fake Telegram
+func newTestBot(t *testing.T) (*Bot, *fakeTelegram) {
+	t.Helper()
+
+	if err := storage.Open(filepath.Join(t.TempDir(), "bolt.db")); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		method := path.Base(r.URL.Path)
+		telegram.mutex.Lock()
+		telegram.requests = append(telegram.requests, telegramRequest{Method: method, Params: r.PostForm})
+		telegram.mutex.Unlock()
+
+		var result interface{} = true
+		switch method {
+		case "getMe":
+			result = map[string]interface{}{"id": 1, "is_bot": true, "first_name": "Bot", "username": "test_bot"}
+		case "sendMessage", "editMessageText":
+			result = map[string]interface{}{"message_id": 1, "date": 0, "chat": map[string]interface{}{"id": 1, "type": "private"}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	api, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("unable to create bot api: %s", err)
+	}
+	return &Bot{api: api}, telegram
+}
+
+// sent Texts of messages sent by the bot, the log is cleared
+func (telegram *fakeTelegram) sent() []string {
+	telegram.mutex.Lock()
+	defer telegram.mutex.Unlock()
+	var texts []string
+	for _, request := range telegram.requests {
+		if request.Method == "sendMessage" {
+			texts = append(texts, request.Params.Get("text"))
+		}
+	}
+	telegram.requests = nil
+	return texts
+}
+
+// textUpdate Private text message from the chat, commands are marked as in Telegram
+func textUpdate(chatId int64, text string) *tgbotapi.Update {
+	message := &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: chatId, FirstName: "Ivan", LanguageCode: "en"},
+		Chat:      &tgbotapi.Chat{ID: chatId, Type: "private"},
+		Text:      text,
+	}
+	if len(text) > 0 && text[0] == '/' {
+		length := len(text)
+		for i, r := range text {
+			if r == ' ' {
+				length = i
+				break
+			}
+		}
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+	return &tgbotapi.Update{Message: message}
+}
+
+// contactUpdate Private message sharing own contact of the chat
+func contactUpdate(chatId int64, phone string) *tgbotapi.Update {
+	update := textUpdate(chatId, "")
+	update.Message.Contact = &tgbotapi.Contact{PhoneNumber: phone, FirstName: "Ivan", UserID: chatId}
+	return update
+}
+
+func TestStartLinkInDialog(t *testing.T) {
+	const chatId = 500
+	bot, telegram := newTestBot(t)
+
+	var contests []*storage.Contest
+	for _, name := range []string{"first", "second"} {
+		contest := &storage.Contest{Name: name}
+		if err := storage.SaveContest(contest); err != nil {
+			t.Fatal(err)
+		}
+		contests = append(contests, contest)
+	}
+
+	if err := bot.processUpdate(textUpdate(chatId, "/start c_"+strconv.FormatUint(contests[0].Id, 10))); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	telegram.sent()
+
+	//link of other contest in the middle of the registration form
+	if err := bot.processUpdate(textUpdate(chatId, "/start c_"+strconv.FormatUint(contests[1].Id, 10))); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	state, err := storage.GetDialogState(chatId)
+	if err != nil || state == nil {
+		t.Fatalf("dialog state = %v, %v, want registration dialog", state, err)
+	}
+	if state.DialogType != DialogTypeRegistration || state.Values[registrationValueContestId] != contests[1].Id {
+		t.Errorf("dialog %s to contest %v, want registration to %d", state.DialogType, state.Values[registrationValueContestId], contests[1].Id)
+	}
+	for key, value := range state.Values {
+		if strings.HasPrefix(key, registrationValueAnswer) {
+			t.Errorf("link saved as answer %s = %v", key, value)
+		}
+	}
+	if sent := telegram.sent(); len(sent) == 0 || !strings.Contains(sent[len(sent)-1], storage.DefaultFormFields(i18n.DefaultLanguage)[0].Prompt) {
+		t.Errorf("first question of the form not asked, sent %q", sent)
+	}
+
+	//unknown team invite ends the dialog
+	if err := bot.processUpdate(textUpdate(chatId, "/start t_unknown")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if state, err := storage.GetDialogState(chatId); err != nil || state != nil {
+		t.Errorf("dialog state = %v, %v, want none", state, err)
+	}
+	if sent := telegram.sent(); len(sent) != 1 {
+		t.Errorf("sent %q, want invite not found message", sent)
+	}
+}
+
+func TestIsStartLink(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"/start c_12", true},
+		{"/start t_abc", true},
+		{"/start", false},
+		{"/start other", false},
+		{"/help c_12", false},
+		{"start c_12", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := isStartLink(textUpdate(1, test.text).Message); got != test.want {
+				t.Errorf("isStartLink(%q) = %t, want %t", test.text, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package bot
+
+import (
+	"contest-registration-bot/i18n"
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	callbackEditRegistration = "edit_registration"
+	callbackWithdraw         = "withdraw"
+	callbackWithdrawConfirm  = "withdraw_confirm"
+	callbackWithdrawCancel   = "withdraw_cancel"
+
+	editRegistrationCallbackField = "field"
+	editRegistrationCallbackDone  = "done"
+
+	editRegistrationValueParticipantId = "ContestParticipantId"
+	editRegistrationValueFieldKey      = "FieldKey"
+)
+
+var (
+	errRegistrationNotFound = errors.New("registration not found")
+	errRegistrationClosed   = errors.New("contest registration closed")
+)
+
+// commandMyRegistrations List participant's registrations with edit and withdraw buttons
+func (bot *Bot) commandMyRegistrations(update *tgbotapi.Update) error {
+	lang := bot.language(update)
+
+	participation, err := storage.GetContestParticipantParticipation(update.FromChat().ID)
+	if err != nil {
+		log.Errorf("/myregistrations: unable to get participation: %s", err)
+		return bot.msg(update, esc(i18n.T(lang, "bot.errors.participation")))
+	}
+
+	registrationsFound := false
+
+	for _, participant := range participation {
+		contest, err := storage.GetContest(participant.ContestId)
+		if err != nil {
+			log.Errorf("/myregistrations: unable to get contest %d: %s", participant.ContestId, err)
+			continue
+		}
+		if contest.Hidden {
+			continue
+		}
+
+		registrationsFound = true
+
+		message := strings.Builder{}
+		message.WriteString("*" + esc(contest.Name) + "*\n")
+		for _, field := range contest.FormFields() {
+			answer := participant.Answer(field.Key)
+			if len(answer) == 0 {
+				answer = "—"
+			}
+			message.WriteString(labeled(field.Title, esc(answer)))
+		}
+
+		captain := participant.ParticipantId == update.FromChat().ID
+		if contest.TeamMode {
+			message.WriteString(teamMembers(lang, &participant))
+			if captain {
+				message.WriteString("\n" + bot.teamInvite(lang, &participant) + "\n")
+			}
+		}
+
+		if !contest.RegistrationOpen(time.Now()) {
+			message.WriteString(italic(i18n.T(lang, "bot.my.closed")))
+			if err := bot.msg(update, message.String()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.my.edit"), callbackData(callbackEditRegistration, participant.Id)),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.my.withdraw"), callbackData(callbackWithdraw, participant.Id)),
+		))
+		if !captain {
+			//only captain edits and withdraws the team
+			keyboard = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.my.leave_team"), callbackData(callbackLeaveTeam, participant.Id)),
+			))
+		}
+		if err := bot.msgWithKeyboard(update, message.String(), keyboard); err != nil {
+			return err
+		}
+	}
+
+	if !registrationsFound {
+		return bot.msg(update, esc(i18n.T(lang, "bot.my.none")))
+	}
+
+	return nil
+}
+
+// processCallback Process inline button press outside of dialogs
+func (bot *Bot) processCallback(update *tgbotapi.Update) error {
+	action, id := parseCallbackData(update.CallbackQuery.Data)
+
+	switch action {
+	case callbackEditRegistration:
+		return bot.callbackEditRegistration(update, id)
+	case callbackWithdraw:
+		return bot.callbackWithdraw(update, id)
+	case callbackWithdrawConfirm:
+		return bot.callbackWithdrawConfirm(update, id)
+	case callbackWithdrawCancel:
+		return bot.editMsg(update, esc(bot.t(update, "bot.my.kept")))
+	case callbackLeaveTeam:
+		return bot.callbackLeaveTeam(update, id)
+	case callbackChooseContest:
+		return bot.callbackChooseContest(update, id)
+	case callbackLanguage:
+		return bot.callbackLanguage(update, id)
+	case callbackConsent, callbackRegistrationConfirm, callbackRegistrationEdit, callbackRegistrationEditCaptain:
+		return bot.editMsg(update, esc(bot.t(update, "bot.registration.expired")))
+	case callbackForgetMe:
+		return bot.callbackForgetMe(update, id)
+	default:
+		log.Warnf("unknown callback: %s", update.CallbackQuery.Data)
+		return nil
+	}
+}
+
+// callbackEditRegistration Start registration edit dialog
+func (bot *Bot) callbackEditRegistration(update *tgbotapi.Update, participantId uint64) error {
+	participant, _, err := ownOpenRegistration(update, participantId)
+	if err != nil {
+		return bot.registrationError(update, err)
+	}
+
+	state := &storage.DialogState{
+		ParticipantId: update.FromChat().ID,
+		DialogType:    DialogTypeEditRegistration,
+		DialogStep:    EditRegistrationStepZero,
+		Values: storage.DialogValues{
+			editRegistrationValueParticipantId: participant.Id,
+		},
+	}
+	return bot.processDialog(update, state)
+}
+
+// callbackWithdraw Ask confirmation of registration withdrawal
+func (bot *Bot) callbackWithdraw(update *tgbotapi.Update, participantId uint64) error {
+	participant, contest, err := ownOpenRegistration(update, participantId)
+	if err != nil {
+		return bot.registrationError(update, err)
+	}
+
+	lang := bot.language(update)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.my.withdraw_yes"), callbackData(callbackWithdrawConfirm, participant.Id)),
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.my.withdraw_no"), callbackData(callbackWithdrawCancel, participant.Id)),
+	))
+	question := i18n.T(lang, "bot.my.withdraw_question", contest.Name)
+	if contest.TeamMode {
+		question = i18n.T(lang, "bot.my.withdraw_question_team", contest.Name)
+	}
+	return bot.msgWithKeyboard(update, esc(question), keyboard)
+}
+
+// callbackWithdrawConfirm Delete participant's registration
+func (bot *Bot) callbackWithdrawConfirm(update *tgbotapi.Update, participantId uint64) error {
+	participant, contest, err := ownOpenRegistration(update, participantId)
+	if err != nil {
+		return bot.registrationError(update, err)
+	}
+
+	if err := storage.DeleteContestParticipant(participant.Id); err != nil {
+		log.Errorf("withdraw: unable to delete participant %d: %s", participant.Id, err)
+		return bot.msg(update, esc(bot.t(update, "bot.my.withdraw_failed")))
+	}
+	webhooks.ParticipantDeleted(participant)
+
+	for _, chatId := range participant.ChatIds() {
+		if chatId == participant.ParticipantId {
+			continue
+		}
+		notification := i18n.T(chatLanguage(chatId), "bot.my.withdraw_team", participant.Name, contest.Name)
+		if err := bot.send(chatId, esc(notification)); err != nil {
+			log.Errorf("withdraw: unable to notify team member %d: %s", chatId, err)
+		}
+	}
+
+	promoted, err := storage.PromoteWaitlistedParticipants(contest.Id)
+	if err != nil {
+		log.Errorf("withdraw: unable to promote waitlisted participants of %d: %s", contest.Id, err)
+	} else if len(promoted) != 0 {
+		for _, participant := range promoted {
+			webhooks.ParticipantUpdated(&participant)
+		}
+		go bot.NotifyPromoted(promoted)
+	}
+
+	return bot.editMsg(update, esc(bot.t(update, "bot.my.withdrawn", contest.Name)))
+}
+
+// registrationError Report registration lookup error to participant
+func (bot *Bot) registrationError(update *tgbotapi.Update, err error) error {
+	if err == errRegistrationNotFound {
+		return bot.msg(update, esc(bot.t(update, "bot.my.not_found")))
+	}
+	if err == errRegistrationClosed {
+		return bot.msg(update, esc(bot.t(update, "bot.my.closed_error")))
+	}
+	log.Errorf("registration error: %s", err)
+	return bot.msg(update, esc(bot.t(update, "bot.errors.generic")))
+}
+
+// ownOpenRegistration Registration of the update's chat to contest open for registration
+func ownOpenRegistration(update *tgbotapi.Update, participantId uint64) (*storage.ContestParticipant, *storage.Contest, error) {
+	participant, err := storage.GetContestParticipant(participantId)
+	if err != nil || participant.ParticipantId != update.FromChat().ID {
+		return nil, nil, errRegistrationNotFound
+	}
+	contest, err := storage.GetContest(participant.ContestId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contest.Hidden {
+		return nil, nil, errRegistrationNotFound
+	}
+	if !contest.RegistrationOpen(time.Now()) {
+		return nil, nil, errRegistrationClosed
+	}
+	return participant, contest, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+var editRegistrationSteps = map[string]DialogAction{
+	EditRegistrationStepZero: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		participantId, _ := state.Values[editRegistrationValueParticipantId].(uint64)
+		_, contest, err := ownOpenRegistration(update, participantId)
+		if err != nil {
+			return true, bot.registrationError(update, err)
+		}
+		state.DialogStep = EditRegistrationStepChoice
+		return false, bot.sendEditRegistrationFields(update, contest)
+	},
+
+	EditRegistrationStepChoice: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		if update.CallbackQuery == nil {
+			return false, bot.msg(update, esc(bot.t(update, "bot.edit.press_button")))
+		}
+
+		participantId, _ := state.Values[editRegistrationValueParticipantId].(uint64)
+		_, contest, err := ownOpenRegistration(update, participantId)
+		if err != nil {
+			return true, bot.registrationError(update, err)
+		}
+
+		data := update.CallbackQuery.Data
+		if data == editRegistrationCallbackDone {
+			return true, bot.msg(update, esc(bot.t(update, "bot.edit.saved")))
+		}
+
+		fieldKey, found := strings.CutPrefix(data, editRegistrationCallbackField+":")
+		if !found {
+			return false, nil
+		}
+		field := contest.FormField(fieldKey)
+		if field == nil {
+			return false, nil
+		}
+
+		state.Values[editRegistrationValueFieldKey] = field.Key
+		state.DialogStep = EditRegistrationStepValue
+		return false, bot.sendFieldPrompt(update, fieldPrompt(bot.language(update), field), field)
+	},
+
+	EditRegistrationStepValue: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		if update.Message == nil {
+			return false, nil
+		}
+
+		participantId, _ := state.Values[editRegistrationValueParticipantId].(uint64)
+		participant, contest, err := ownOpenRegistration(update, participantId)
+		if err != nil {
+			return true, bot.registrationError(update, err)
+		}
+
+		fieldKey, _ := state.Values[editRegistrationValueFieldKey].(string)
+		field := contest.FormField(fieldKey)
+		if field == nil {
+			state.DialogStep = EditRegistrationStepChoice
+			return false, bot.sendEditRegistrationFields(update, contest)
+		}
+
+		value, shared, err := fieldAnswer(update.Message, field)
+		if err != nil {
+			return false, bot.sendFieldPrompt(update, answerError(bot.language(update), field, err), field)
+		}
+		if field.AcceptsContact() {
+			bot.removeContactKeyboard(update)
+		}
+
+		participant.Phone = answerPhone(field, participant.Answer(field.Key), value, participant.Phone, shared)
+		participant.SetAnswer(field.Key, value)
+		if err := storage.SaveContestParticipant(participant); err != nil {
+			log.Errorf("edit registration: unable to save participant %d: %s", participant.Id, err)
+			return true, bot.msg(update, esc(bot.t(update, "bot.edit.save_failed")))
+		}
+		webhooks.ParticipantUpdated(participant)
+
+		delete(state.Values, editRegistrationValueFieldKey)
+		state.DialogStep = EditRegistrationStepChoice
+		return false, bot.sendEditRegistrationFields(update, contest)
+	},
+}
+
+// sendEditRegistrationFields Send keyboard to choose registration question to answer again
+func (bot *Bot) sendEditRegistrationFields(update *tgbotapi.Update, contest *storage.Contest) error {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, field := range contest.FormFields() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(field.Title, editRegistrationCallbackField+":"+field.Key),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(bot.t(update, "bot.edit.done"), editRegistrationCallbackDone),
+	))
+
+	message := esc(bot.t(update, "bot.edit.question", contest.Name))
+	return bot.msgWithKeyboard(update, message, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
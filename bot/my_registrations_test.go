@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"contest-registration-bot/storage"
+	"testing"
+)
+
+func TestEditRegistrationPhone(t *testing.T) {
+	const chatId = 500
+	const phone = "+79001234567"
+
+	tests := []struct {
+		name     string
+		fieldKey string
+		answer   string
+		contact  string
+		want     string
+	}{
+		{"typed contacts clear shared phone", storage.FormFieldContacts, "mail@example.com", "", ""},
+		{"shared contact sets phone", storage.FormFieldContacts, "", "+79007654321", "+79007654321"},
+		{"other answer keeps phone", "school", "School 2", "", phone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bot, _ := newTestBot(t)
+			contest := &storage.Contest{Name: "contest"}
+			if err := storage.SaveContest(contest); err != nil {
+				t.Fatal(err)
+			}
+			participant := &storage.ContestParticipant{ContestId: contest.Id, ParticipantId: chatId, Phone: phone}
+			participant.SetAnswer(storage.FormFieldName, "Ivan Petrov")
+			participant.SetAnswer("school", "School 1")
+			participant.SetAnswer(storage.FormFieldContacts, phone)
+			if err := storage.SaveContestParticipant(participant); err != nil {
+				t.Fatal(err)
+			}
+			state := &storage.DialogState{
+				ParticipantId: chatId,
+				DialogType:    DialogTypeEditRegistration,
+				DialogStep:    EditRegistrationStepValue,
+				Values: storage.DialogValues{
+					editRegistrationValueParticipantId: participant.Id,
+					editRegistrationValueFieldKey:      test.fieldKey,
+				},
+			}
+			if err := storage.SaveDialogState(state); err != nil {
+				t.Fatal(err)
+			}
+
+			update := textUpdate(chatId, test.answer)
+			if test.contact != "" {
+				update = contactUpdate(chatId, test.contact)
+			}
+			if err := bot.processUpdate(update); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			saved, err := storage.GetContestParticipant(participant.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Phone != test.want {
+				t.Errorf("phone = %q, want %q, answers %v", saved.Phone, test.want, saved.Answers)
+			}
+		})
+	}
+}
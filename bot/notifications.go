@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"contest-registration-bot/i18n"
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+	"github.com/timshannon/bolthold"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	deliveryMaxAttempts   = 5
+	deliveryRetryDelay    = 2 * time.Second
+	deliveryMaxRetryDelay = time.Minute
+	deliveryInterval      = 50 * time.Millisecond
+)
+
+var errDeliverySuperseded = errors.New("notification delivery superseded")
+
+// SendNotifications Send notification to all participants of the contest
+func (bot *Bot) SendNotifications(notification *storage.ContestNotification) error {
+	contest, err := storage.GetContest(notification.ContestId)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := storage.CreateNotificationDeliveries(notification, time.Now())
+	if err != nil {
+		return err
+	}
+	webhooks.NotificationSent(notification, NotificationText(i18n.DefaultLanguage, contest, notification))
+
+	go bot.deliverNotification(contest, notification, deliveries)
+
+	return nil
+}
+
+// resumeNotificationDeliveries Continue deliveries interrupted by restart
+func (bot *Bot) resumeNotificationDeliveries() {
+	deliveries, err := storage.GetPendingNotificationDeliveries()
+	if err != nil {
+		log.Errorf("unable to get pending notification deliveries: %s", err)
+		return
+	}
+
+	var notificationIds []uint64
+	notificationDeliveries := make(map[uint64][]storage.NotificationDelivery)
+	for _, delivery := range deliveries {
+		if _, ok := notificationDeliveries[delivery.NotificationId]; !ok {
+			notificationIds = append(notificationIds, delivery.NotificationId)
+		}
+		notificationDeliveries[delivery.NotificationId] = append(notificationDeliveries[delivery.NotificationId], delivery)
+	}
+
+	for _, notificationId := range notificationIds {
+		notification, err := storage.GetContestNotification(notificationId)
+		if err != nil {
+			log.Errorf("unable to get notification %d of pending deliveries: %s", notificationId, err)
+			continue
+		}
+		contest, err := storage.GetContest(notification.ContestId)
+		if err != nil {
+			log.Errorf("unable to get contest %d of pending deliveries: %s", notification.ContestId, err)
+			continue
+		}
+		log.Infof("resuming %d deliveries of notification %d", len(notificationDeliveries[notificationId]), notificationId)
+		bot.deliverNotification(contest, notification, notificationDeliveries[notificationId])
+	}
+}
+
+// NotificationText Notification message in given language,
+// automatic reminders are rendered from their message key with current contest data
+func NotificationText(lang string, contest *storage.Contest, notification *storage.ContestNotification) string {
+	reminder := notification.Reminder()
+	if reminder == nil {
+		return notification.Message
+	}
+	startsAt := ""
+	if !contest.StartsAt.IsZero() {
+		startsAt = formatDateTime(lang, contest.InLocation(contest.StartsAt))
+	}
+	return i18n.T(lang, reminder.Message, contest.Name, startsAt, contest.Where)
+}
+
+// deliverNotification Send notification one by one, recording delivery results
+func (bot *Bot) deliverNotification(contest *storage.Contest, notification *storage.ContestNotification, deliveries []storage.NotificationDelivery) {
+	bot.deliveryMutex.Lock()
+	defer bot.deliveryMutex.Unlock()
+
+	//message heading and reminders are in the language of each recipient
+	messageTexts := make(map[string]string)
+
+	for _, delivery := range deliveries {
+		lang := chatLanguage(delivery.ParticipantId)
+		messageText, ok := messageTexts[lang]
+		if !ok {
+			messageBuilder := strings.Builder{}
+			messageBuilder.WriteString(bold(i18n.T(lang, "bot.notification", contest.Name)) + ":\n\n")
+			messageBuilder.WriteString(esc(NotificationText(lang, contest, notification)))
+			messageText = messageBuilder.String()
+			messageTexts[lang] = messageText
+		}
+
+		if err := bot.deliver(&delivery, messageText); err != nil {
+			if err == errDeliverySuperseded {
+				log.Infof("deliveries of notification %d superseded", notification.Id)
+			} else {
+				log.Errorf("unable to save delivery %d of notification %d: %s", delivery.Id, notification.Id, err)
+			}
+			return
+		}
+		time.Sleep(deliveryInterval)
+	}
+}
+
+// deliver Send message to delivery recipient with retries
+func (bot *Bot) deliver(delivery *storage.NotificationDelivery, messageText string) error {
+	message := tgbotapi.NewMessage(delivery.ParticipantId, messageText)
+	message.ParseMode = tgbotapi.ModeMarkdownV2
+
+	for {
+		delivery.Attempts++
+		_, err := bot.api.Send(message)
+
+		retry, delay := false, time.Duration(0)
+
+		if err == nil {
+			delivery.Status = storage.DeliveryStatusSent
+			delivery.Error = ""
+		} else {
+			delivery.Error = err.Error()
+			var apiError *tgbotapi.Error
+			if errors.As(err, &apiError) {
+				switch {
+				case apiError.Code == http.StatusTooManyRequests:
+					retry, delay = true, time.Duration(apiError.RetryAfter)*time.Second
+				case apiError.Code == http.StatusForbidden:
+					delivery.Status = storage.DeliveryStatusBlocked
+				case apiError.Code >= http.StatusInternalServerError:
+					retry = true
+				default:
+					delivery.Status = storage.DeliveryStatusFailed
+				}
+			} else {
+				//network errors
+				retry = true
+			}
+			if retry && delivery.Attempts >= deliveryMaxAttempts {
+				retry = false
+				delivery.Status = storage.DeliveryStatusFailed
+			}
+			log.Warnf("unable to deliver notification %d to %d (attempt %d): %s",
+				delivery.NotificationId, delivery.ParticipantId, delivery.Attempts, err)
+		}
+
+		if err := storage.SaveNotificationDelivery(delivery); err != nil {
+			if err == bolthold.ErrNotFound {
+				return errDeliverySuperseded
+			}
+			return err
+		}
+
+		if !retry {
+			return nil
+		}
+
+		if delay == 0 {
+			delay = deliveryRetryDelay << (delivery.Attempts - 1)
+			if delay > deliveryMaxRetryDelay {
+				delay = deliveryMaxRetryDelay
+			}
+		}
+		time.Sleep(delay)
+	}
+}
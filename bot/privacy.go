@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"contest-registration-bot/i18n"
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	callbackConsent  = "consent"
+	callbackForgetMe = "forget_me"
+
+	//button ids of consent and erasure confirmation
+	answerNo  = 0
+	answerYes = 1
+
+	consentValueAt      = "ConsentAt"
+	consentValueVersion = "ConsentVersion"
+)
+
+// consentRequired Personal data policy is configured, participants accept it before registration
+func (bot *Bot) consentRequired() bool {
+	return len(bot.config.ConsentPolicy) != 0 || len(bot.config.ConsentPolicyURL) != 0
+}
+
+// askConsent Send personal data policy with accept and decline buttons after already escaped intro
+func (bot *Bot) askConsent(update *tgbotapi.Update, intro string) error {
+	lang := bot.language(update)
+
+	message := strings.Builder{}
+	message.WriteString(intro)
+	message.WriteString(bold(i18n.T(lang, "bot.consent.title")) + "\n")
+	if len(bot.config.ConsentPolicy) != 0 {
+		message.WriteString(esc(bot.config.ConsentPolicy) + "\n")
+	}
+	if len(bot.config.ConsentPolicyURL) != 0 {
+		message.WriteString(esc(bot.config.ConsentPolicyURL) + "\n")
+	}
+	message.WriteString("\n" + esc(i18n.T(lang, "bot.consent.prompt")))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.consent.accept"), callbackData(callbackConsent, answerYes)),
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.consent.decline"), callbackData(callbackConsent, answerNo)),
+	))
+	return bot.msgWithKeyboard(update, message.String(), keyboard)
+}
+
+// consentAnswer Process consent buttons, accepted consent is saved to dialog values.
+// Dialog is done when consent is declined
+func (bot *Bot) consentAnswer(update *tgbotapi.Update, state *storage.DialogState) (accepted bool, done bool, err error) {
+	if update.CallbackQuery == nil {
+		return false, false, bot.msg(update, esc(bot.t(update, "bot.consent.press_button")))
+	}
+
+	action, answer := parseCallbackData(update.CallbackQuery.Data)
+	if action != callbackConsent {
+		return false, false, nil
+	}
+	if answer != answerYes {
+		return false, true, bot.editMsg(update, esc(bot.t(update, "bot.consent.declined")))
+	}
+
+	state.Values[consentValueAt] = time.Now().Unix()
+	state.Values[consentValueVersion] = bot.config.ConsentPolicyVersion
+	if err := bot.editMsg(update, esc(bot.t(update, "bot.consent.accepted"))); err != nil {
+		log.Errorf("consent: unable to edit message: %s", err)
+	}
+	return true, false, nil
+}
+
+// consentValues Time and policy version of consent given in the dialog, zero time without consent
+func consentValues(state *storage.DialogState) (time.Time, string) {
+	consentAt, ok := state.Values[consentValueAt].(int64)
+	if !ok {
+		return time.Time{}, ""
+	}
+	version, _ := state.Values[consentValueVersion].(string)
+	return time.Unix(consentAt, 0), version
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// commandForgetMe Ask to confirm erasure of chat's personal data
+func (bot *Bot) commandForgetMe(update *tgbotapi.Update) error {
+	lang := bot.language(update)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.forget.confirm"), callbackData(callbackForgetMe, answerYes)),
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.forget.cancel"), callbackData(callbackForgetMe, answerNo)),
+	))
+	return bot.msgWithKeyboard(update, esc(i18n.T(lang, "bot.forget.prompt")), keyboard)
+}
+
+// callbackForgetMe Anonymize all records of the chat after confirmation
+func (bot *Bot) callbackForgetMe(update *tgbotapi.Update, answer uint64) error {
+	//chat settings are erased too, language is taken before
+	lang := bot.language(update)
+	if answer != answerYes {
+		return bot.editMsg(update, esc(i18n.T(lang, "bot.forget.canceled")))
+	}
+
+	chatId := update.FromChat().ID
+	result, err := storage.AnonymizeChat(chatId)
+	if err != nil {
+		log.Errorf("forget me: unable to anonymize chat %d: %s", chatId, err)
+		return bot.editMsg(update, esc(i18n.T(lang, "bot.errors.failed")))
+	}
+	for _, participant := range result.Participants {
+		webhooks.ParticipantUpdated(&participant)
+	}
+	log.Infof("chat %d data erased on request: %d registrations, %d notification deliveries, %d webhook deliveries",
+		chatId, len(result.Participants), result.Deliveries, result.WebhookDeliveries)
+
+	return bot.editMsg(update, esc(i18n.T(lang, "bot.forget.done", len(result.Participants))))
+}
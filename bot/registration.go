@@ -1,108 +1,410 @@
 package bot
 
 import (
+	"contest-registration-bot/i18n"
 	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	log "github.com/sirupsen/logrus"
 	"strings"
+	"time"
+)
+
+const (
+	callbackRegistrationConfirm     = "registration_confirm"
+	callbackRegistrationEdit        = "registration_edit"
+	callbackRegistrationEditCaptain = "registration_edit_captain"
+
+	registrationValueContestId   = "ContestId"
+	registrationValueFieldIndex  = "FieldIndex"
+	registrationValueAnswer      = "Answer."
+	registrationValueCaptainName = "CaptainName"
+	registrationValuePhone       = "Phone"
+	//answer is changed from the summary, dialog returns to it after the answer
+	registrationValueReview = "Review"
+
+	maxMemberNameLength = 100
 )
 
 var registrationSteps = map[string]DialogAction{
 	RegistrationStepZero: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		contest, err := registrationContest(state)
+		if err != nil {
+			log.Errorf("registration: unable to get contest: %s", err)
+			return true, bot.msg(update, esc(bot.t(update, "bot.registration.contest_not_found")))
+		}
+
+		fields := contest.FormFields()
+
+		lang := bot.language(update)
 		message := strings.Builder{}
-		message.WriteString(esc("Начинаем регистрацию на контест.\n"))
-		message.WriteString(esc("Чтобы отменить регистрацию, в любой момент введите /cancel\n\n"))
-		message.WriteString(esc("Введите Ваши фамилию, имя и отчество\n"))
-		message.WriteString("_" + esc("например: Иванов Иван Иванович") + "_")
-		if err := bot.msg(update, message.String()); err != nil {
+		if contest.TeamMode {
+			message.WriteString(esc(i18n.T(lang, "bot.registration.start_team")) + "\n")
+		} else {
+			message.WriteString(esc(i18n.T(lang, "bot.registration.start")) + "\n")
+		}
+		message.WriteString(esc(i18n.T(lang, "bot.registration.cancel_hint")) + "\n\n")
+		if bot.consentRequired() {
+			state.DialogStep = RegistrationStepConsent
+			return false, bot.askConsent(update, message.String())
+		}
+		message.WriteString(fieldPrompt(lang, &fields[0]))
+		if err := bot.sendFieldPrompt(update, message.String(), &fields[0]); err != nil {
 			return false, err
 		}
-		state.DialogStep = RegistrationStepName
+		state.Values[registrationValueFieldIndex] = 0
+		state.DialogStep = RegistrationStepField
 		return false, nil
 	},
 
-	RegistrationStepName: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
-		name := trim(update.Message.Text, 100)
-		if len(name) == 0 {
-			if err := bot.msg(update, esc("Попробуйте ввести ФИО еще раз")); err != nil {
-				return false, err
-			}
-			return false, nil
+	RegistrationStepConsent: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		accepted, done, err := bot.consentAnswer(update, state)
+		if !accepted {
+			return done, err
 		}
-		message := strings.Builder{}
-		message.WriteString(esc("Введите название Вашей школы или ВУЗа, а также класс (или курс и группу)\n"))
-		message.WriteString("_" + esc("например: ПсковГУ, 1 курс, группа 081-0902") + "_")
-		if err := bot.msg(update, message.String()); err != nil {
+
+		contest, err := registrationContest(state)
+		if err != nil {
+			log.Errorf("registration: unable to get contest: %s", err)
+			return true, bot.msg(update, esc(bot.t(update, "bot.registration.contest_not_found")))
+		}
+
+		fields := contest.FormFields()
+		if err := bot.sendFieldPrompt(update, fieldPrompt(bot.language(update), &fields[0]), &fields[0]); err != nil {
 			return false, err
 		}
-		state.Values["Name"] = name
-		state.DialogStep = RegistrationStepSchool
+		state.Values[registrationValueFieldIndex] = 0
+		state.DialogStep = RegistrationStepField
 		return false, nil
 	},
 
-	RegistrationStepSchool: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
-		school := trim(update.Message.Text, 200)
-		if len(school) == 0 {
-			if err := bot.msg(update, esc("Попробуйте ввести название образовательной организации еще раз")); err != nil {
+	RegistrationStepField: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		if update.Message == nil {
+			return false, nil
+		}
+
+		contest, err := registrationContest(state)
+		if err != nil {
+			log.Errorf("registration: unable to get contest: %s", err)
+			return true, bot.msg(update, esc(bot.t(update, "bot.registration.contest_not_found")))
+		}
+
+		fields := contest.FormFields()
+		fieldIndex, _ := state.Values[registrationValueFieldIndex].(int)
+		if fieldIndex >= len(fields) {
+			log.Errorf("registration: field %d of contest %d not found", fieldIndex, contest.Id)
+			return true, bot.msg(update, esc(bot.t(update, "bot.registration.form_changed")))
+		}
+
+		field := &fields[fieldIndex]
+		value, shared, err := fieldAnswer(update.Message, field)
+		if err != nil {
+			return false, bot.sendFieldPrompt(update, answerError(bot.language(update), field, err), field)
+		}
+		previous, _ := state.Values[registrationValueAnswer+field.Key].(string)
+		phone, _ := state.Values[registrationValuePhone].(string)
+		state.Values[registrationValueAnswer+field.Key] = value
+		if phone = answerPhone(field, previous, value, phone, shared); phone != "" {
+			state.Values[registrationValuePhone] = phone
+		} else {
+			delete(state.Values, registrationValuePhone)
+		}
+		if field.AcceptsContact() {
+			bot.removeContactKeyboard(update)
+		}
+
+		if review, _ := state.Values[registrationValueReview].(bool); review {
+			return bot.registrationSummary(update, state, contest)
+		}
+
+		fieldIndex++
+		if fieldIndex < len(fields) {
+			if err := bot.sendFieldPrompt(update, fieldPrompt(bot.language(update), &fields[fieldIndex]), &fields[fieldIndex]); err != nil {
 				return false, err
 			}
+			state.Values[registrationValueFieldIndex] = fieldIndex
 			return false, nil
 		}
-		message := strings.Builder{}
-		message.WriteString(esc("Введите Ваши контактные данные, например номер телефона и адрес электронной почты, либо напишите, как в Вами можно связаться\n"))
-		message.WriteString("_" + esc("например: +7-000-000-00-00, mail@example.com") + "_")
-		if err := bot.msg(update, message.String()); err != nil {
-			return false, err
+
+		if contest.TeamMode {
+			state.DialogStep = RegistrationStepCaptainName
+			return false, bot.msg(update, esc(bot.t(update, "bot.registration.captain_name")))
 		}
-		state.Values["School"] = school
-		state.DialogStep = RegistrationStepContacts
-		return false, nil
+
+		return bot.registrationSummary(update, state, contest)
 	},
 
-	RegistrationStepContacts: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
-		contacts := trim(update.Message.Text, 100)
-		if len(contacts) == 0 {
-			if err := bot.msg(update, esc("Попробуйте ввести контакты еще раз")); err != nil {
-				return false, err
-			}
+	RegistrationStepCaptainName: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		if update.Message == nil {
 			return false, nil
 		}
-		message := strings.Builder{}
-		message.WriteString(esc("И последний вопрос, какие предпочитаете языки и среды программирования\n"))
-		message.WriteString("_" + esc("например: C++, Visual Studio") + "_")
-		if err := bot.msg(update, message.String()); err != nil {
-			return false, err
+
+		contest, err := registrationContest(state)
+		if err != nil {
+			log.Errorf("registration: unable to get contest: %s", err)
+			return true, bot.msg(update, esc(bot.t(update, "bot.registration.contest_not_found")))
 		}
-		state.Values["Contacts"] = contacts
-		state.DialogStep = RegistrationStepLanguages
-		return false, nil
+
+		name := trim(update.Message.Text, maxMemberNameLength)
+		if len(name) == 0 {
+			return false, bot.msg(update, esc(bot.t(update, "bot.registration.name_example")))
+		}
+		state.Values[registrationValueCaptainName] = name
+
+		return bot.registrationSummary(update, state, contest)
 	},
 
-	RegistrationStepLanguages: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
-		languages := trim(update.Message.Text, 200)
-		participant := &storage.ContestParticipant{
-			ParticipantId: state.ParticipantId,
-			ContestId:     state.Values["ContestId"].(uint64),
-			Name:          state.Values["Name"].(string),
-			School:        state.Values["School"].(string),
-			Contacts:      state.Values["Contacts"].(string),
-			Languages:     languages,
-		}
-		if err := storage.SaveContestParticipant(participant); err != nil {
-			log.Errorf("registration: unable to save contest participant: %s", err)
-			if err := bot.msg(update, esc("Не удалось зарегистрироваться на контест. Попробуйте еще раз")); err != nil {
-				return true, err
+	RegistrationStepSummary: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		if update.CallbackQuery == nil {
+			return false, bot.msg(update, esc(bot.t(update, "bot.registration.summary_press_button")))
+		}
+
+		contest, err := registrationContest(state)
+		if err != nil {
+			log.Errorf("registration: unable to get contest: %s", err)
+			return true, bot.msg(update, esc(bot.t(update, "bot.registration.contest_not_found")))
+		}
+
+		lang := bot.language(update)
+		fields := contest.FormFields()
+		action, fieldIndex := parseCallbackData(update.CallbackQuery.Data)
+
+		switch action {
+		case callbackRegistrationConfirm:
+			if err := bot.editMsg(update, registrationAnswers(lang, state, contest)); err != nil {
+				log.Errorf("registration: unable to edit message: %s", err)
+			}
+			var members []storage.TeamMember
+			if contest.TeamMode {
+				name, _ := state.Values[registrationValueCaptainName].(string)
+				consentAt, consentVersion := consentValues(state)
+				members = []storage.TeamMember{{
+					ChatId:         state.ParticipantId,
+					Name:           name,
+					JoinedAt:       time.Now(),
+					ConsentAt:      consentAt,
+					ConsentVersion: consentVersion,
+				}}
+			}
+			return bot.register(update, state, contest, members)
+
+		case callbackRegistrationEdit:
+			if fieldIndex >= uint64(len(fields)) {
+				return false, nil
 			}
-			return true, nil
+			if err := bot.editMsg(update, registrationAnswers(lang, state, contest)); err != nil {
+				log.Errorf("registration: unable to edit message: %s", err)
+			}
+			state.Values[registrationValueFieldIndex] = int(fieldIndex)
+			state.Values[registrationValueReview] = true
+			state.DialogStep = RegistrationStepField
+			return false, bot.sendFieldPrompt(update, fieldPrompt(lang, &fields[fieldIndex]), &fields[fieldIndex])
+
+		case callbackRegistrationEditCaptain:
+			if !contest.TeamMode {
+				return false, nil
+			}
+			if err := bot.editMsg(update, registrationAnswers(lang, state, contest)); err != nil {
+				log.Errorf("registration: unable to edit message: %s", err)
+			}
+			state.DialogStep = RegistrationStepCaptainName
+			return false, bot.msg(update, esc(i18n.T(lang, "bot.registration.captain_name")))
 		}
-		message := strings.Builder{}
-		message.WriteString(esc("Спасибо за ответы. Регистрация завершена :)\n"))
-		message.WriteString("*Логин:* `" + esc(participant.Login) + "`\n")
-		message.WriteString("*Пароль:* `" + esc(participant.Password) + "`\n\n")
-		message.WriteString("Посмотреть сведения о контесте и проверить регистрационные данные можно через команду /contests")
-		if err := bot.msg(update, message.String()); err != nil {
+
+		return false, nil
+	},
+}
+
+// registrationSummary Show collected answers with buttons to confirm registration or answer any question again
+func (bot *Bot) registrationSummary(update *tgbotapi.Update, state *storage.DialogState, contest *storage.Contest) (bool, error) {
+	lang := bot.language(update)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.registration.confirm"), callbackData(callbackRegistrationConfirm, 0)),
+	))
+	for i, field := range contest.FormFields() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.registration.edit_field", field.Title), callbackData(callbackRegistrationEdit, uint64(i))),
+		))
+	}
+	if contest.TeamMode {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "bot.registration.edit_field", i18n.T(lang, "bot.labels.captain")), callbackData(callbackRegistrationEditCaptain, 0)),
+		))
+	}
+
+	message := strings.Builder{}
+	message.WriteString(bold(i18n.T(lang, "bot.registration.summary")) + "\n\n")
+	message.WriteString(registrationAnswers(lang, state, contest) + "\n")
+	message.WriteString(esc(i18n.T(lang, "bot.registration.summary_hint")))
+
+	delete(state.Values, registrationValueReview)
+	state.DialogStep = RegistrationStepSummary
+	return false, bot.msgWithKeyboard(update, message.String(), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// registrationAnswers Answers collected in the dialog, one per line
+func registrationAnswers(lang string, state *storage.DialogState, contest *storage.Contest) string {
+	message := strings.Builder{}
+	for _, field := range contest.FormFields() {
+		answer, _ := state.Values[registrationValueAnswer+field.Key].(string)
+		if len(answer) == 0 {
+			answer = "—"
+		}
+		message.WriteString(labeled(field.Title, esc(answer)))
+	}
+	if contest.TeamMode {
+		name, _ := state.Values[registrationValueCaptainName].(string)
+		message.WriteString(labeled(i18n.T(lang, "bot.labels.captain"), esc(name)))
+	}
+	return message.String()
+}
+
+// register Save participant with answers collected in the dialog, team members are set in team mode
+func (bot *Bot) register(update *tgbotapi.Update, state *storage.DialogState, contest *storage.Contest, members []storage.TeamMember) (bool, error) {
+	if !contest.RegistrationOpen(time.Now()) {
+		return true, bot.msg(update, esc(bot.t(update, "bot.registration.closed")))
+	}
+
+	consentAt, consentVersion := consentValues(state)
+	phone, _ := state.Values[registrationValuePhone].(string)
+	participant := &storage.ContestParticipant{
+		ParticipantId:  state.ParticipantId,
+		ContestId:      contest.Id,
+		Members:        members,
+		ConsentAt:      consentAt,
+		ConsentVersion: consentVersion,
+		Phone:          phone,
+	}
+	if from := update.SentFrom(); from != nil {
+		participant.TelegramUsername = from.UserName
+		participant.FirstName = from.FirstName
+		participant.LastName = from.LastName
+	}
+	for _, field := range contest.FormFields() {
+		answer, _ := state.Values[registrationValueAnswer+field.Key].(string)
+		participant.SetAnswer(field.Key, answer)
+	}
+	if err := storage.RegisterContestParticipant(participant); err != nil {
+		log.Errorf("registration: unable to save contest participant: %s", err)
+		if err := bot.msg(update, esc(bot.t(update, "bot.registration.failed"))); err != nil {
 			return true, err
 		}
 		return true, nil
-	},
+	}
+	webhooks.ParticipantCreated(participant)
+
+	lang := bot.language(update)
+	message := strings.Builder{}
+	if participant.Waitlisted {
+		position, err := storage.GetWaitlistPosition(participant)
+		if err != nil {
+			log.Errorf("registration: unable to get waitlist position of %d: %s", participant.Id, err)
+		}
+		message.WriteString(esc(i18n.T(lang, "bot.registration.waitlisted")) + "\n")
+		if position > 0 {
+			message.WriteString(labeled(i18n.T(lang, "bot.labels.waitlist_position"), fmt.Sprint(position)))
+		}
+		message.WriteString(esc(i18n.T(lang, "bot.registration.waitlist_promise")))
+	} else {
+		message.WriteString(esc(i18n.T(lang, "bot.registration.done")) + "\n")
+		message.WriteString(credentials(lang, participant) + "\n")
+		message.WriteString(esc(i18n.T(lang, "bot.registration.contests_hint")))
+	}
+	if contest.TeamMode {
+		message.WriteString("\n\n" + bot.teamInvite(lang, participant))
+	}
+	return true, bot.msg(update, message.String())
+}
+
+func registrationContest(state *storage.DialogState) (*storage.Contest, error) {
+	contestId, _ := state.Values[registrationValueContestId].(uint64)
+	return storage.GetContest(contestId)
+}
+
+// fieldPrompt Question to the registration form field
+func fieldPrompt(lang string, field *storage.FormField) string {
+	message := strings.Builder{}
+	message.WriteString(esc(field.Prompt))
+	if !field.Required {
+		message.WriteString(esc(i18n.T(lang, "bot.registration.optional")))
+	}
+	if field.Type == storage.FormFieldTypeChoice && len(field.Choices) != 0 {
+		message.WriteString("\n" + esc(i18n.T(lang, "bot.registration.choices", strings.Join(field.Choices, ", "))))
+	}
+	if len(field.Example) != 0 {
+		message.WriteString("\n" + italic(i18n.T(lang, "bot.registration.example", field.Example)))
+	}
+	return message.String()
+}
+
+// sendFieldPrompt Send the question, phone questions get a button to share Telegram contact
+func (bot *Bot) sendFieldPrompt(update *tgbotapi.Update, message string, field *storage.FormField) error {
+	if !field.AcceptsContact() {
+		return bot.msg(update, message)
+	}
+	keyboard := tgbotapi.NewOneTimeReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButtonContact(bot.t(update, "bot.registration.share_contact")),
+	))
+	return bot.msgWithReplyMarkup(update, message, keyboard)
+}
+
+// removeContactKeyboard Confirm the answer to phone question hiding contact sharing button
+func (bot *Bot) removeContactKeyboard(update *tgbotapi.Update) {
+	message := esc(bot.t(update, "bot.registration.answer_accepted"))
+	if err := bot.msgWithReplyMarkup(update, message, tgbotapi.NewRemoveKeyboard(false)); err != nil {
+		log.Errorf("unable to remove contact keyboard: %s", err)
+	}
+}
+
+// fieldAnswer Normalized answer to the question, shared contact answers phone questions with its phone number
+func fieldAnswer(message *tgbotapi.Message, field *storage.FormField) (value string, shared bool, err error) {
+	text := message.Text
+	if contact := message.Contact; contact != nil {
+		if !field.AcceptsContact() {
+			return "", false, &storage.ValidationError{Message: "bot.registration.retry"}
+		}
+		//only own contact has verified phone number
+		if message.From == nil || contact.UserID != message.From.ID {
+			return "", false, &storage.ValidationError{Message: "bot.registration.contact_not_own"}
+		}
+		text = contact.PhoneNumber
+		if !strings.HasPrefix(text, "+") {
+			text = "+" + text
+		}
+		shared = true
+	} else if !field.Required && strings.TrimSpace(text) == "-" {
+		text = ""
+	}
+
+	value, err = field.Normalize(text)
+	if err != nil {
+		return "", false, err
+	}
+	return value, shared, nil
+}
+
+// answerPhone Verified phone after the answer to the field: shared contact sets it,
+// typed answer to the field answered with the shared contact clears it
+func answerPhone(field *storage.FormField, previous, value, phone string, shared bool) string {
+	if shared {
+		return value
+	}
+	if phone != "" && field.AcceptsContact() && previous == phone && value != phone {
+		return ""
+	}
+	return phone
+}
+
+// answerError Reason why the answer was rejected followed by the question
+func answerError(lang string, field *storage.FormField, err error) string {
+	message := i18n.T(lang, "bot.registration.retry")
+	var validationErr *storage.ValidationError
+	if errors.As(err, &validationErr) {
+		message = i18n.T(lang, validationErr.Message, validationErr.Args...)
+	}
+	return esc(message) + "\n\n" + fieldPrompt(lang, field)
 }
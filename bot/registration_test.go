@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"contest-registration-bot/storage"
+	"testing"
+)
+
+func TestAnswerPhone(t *testing.T) {
+	phoneField := &storage.FormField{Key: "phone", Type: storage.FormFieldTypePhone}
+	contactsField := &storage.FormField{Key: storage.FormFieldContacts, Type: storage.FormFieldTypeText}
+	schoolField := &storage.FormField{Key: "school", Type: storage.FormFieldTypeText}
+	const phone = "+79001234567"
+
+	tests := []struct {
+		name     string
+		field    *storage.FormField
+		previous string
+		value    string
+		phone    string
+		shared   bool
+		want     string
+	}{
+		{"shared contact sets phone", phoneField, "", phone, "", true, phone},
+		{"shared contact replaces phone", contactsField, "+79000000000", phone, "+79000000000", true, phone},
+		{"typed answer replaces shared contact", contactsField, phone, "mail@example.com", phone, false, ""},
+		{"typed phone replaces shared contact", phoneField, phone, "+79007654321", phone, false, ""},
+		{"same phone typed again", phoneField, phone, phone, phone, false, phone},
+		{"other contact field typed", contactsField, "", "mail@example.com", phone, false, phone},
+		{"other question", schoolField, phone, "School 1", phone, false, phone},
+		{"typed answer without shared contact", phoneField, "", "+79007654321", "", false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := answerPhone(test.field, test.previous, test.value, test.phone, test.shared); got != test.want {
+				t.Errorf("answerPhone() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"contest-registration-bot/i18n"
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	// teamInvitePrefix Prefix of /start payload in team invite links
+	teamInvitePrefix = "t_"
+
+	callbackLeaveTeam = "leave_team"
+
+	joinTeamValueTeamId = "TeamId"
+)
+
+var errTeamClosed = errors.New("team contest registration closed")
+
+// teamInvite Invite code and link to join the team
+func (bot *Bot) teamInvite(lang string, team *storage.ContestParticipant) string {
+	message := strings.Builder{}
+	message.WriteString(labeled(i18n.T(lang, "bot.team.invite_code"), "`"+esc(team.InviteCode)+"`"))
+	message.WriteString(esc(i18n.T(lang, "bot.team.members_count", len(team.Members))) + " ")
+	message.WriteString(esc(i18n.T(lang, "bot.team.invite_hint", bot.teamInviteLink(team), team.InviteCode)))
+	return message.String()
+}
+
+// teamInviteLink Deep link starting the bot with team invite code
+func (bot *Bot) teamInviteLink(team *storage.ContestParticipant) string {
+	return bot.startLink(teamInvitePrefix + team.InviteCode)
+}
+
+// teamMembers Heading and names of team members, one per line
+func teamMembers(lang string, team *storage.ContestParticipant) string {
+	message := strings.Builder{}
+	message.WriteString("*" + esc(i18n.T(lang, "bot.labels.team")) + ":*\n")
+	for _, member := range team.Members {
+		message.WriteString(esc("— " + member.Name))
+		if member.ChatId != 0 && member.ChatId == team.ParticipantId {
+			message.WriteString(" " + italic(i18n.T(lang, "bot.team.captain")))
+		}
+		message.WriteRune('\n')
+	}
+	return message.String()
+}
+
+// commandJoin Join the team with invite code
+func (bot *Bot) commandJoin(update *tgbotapi.Update) error {
+	code := strings.TrimSpace(update.Message.CommandArguments())
+	if len(code) == 0 {
+		return bot.msg(update, esc(bot.t(update, "bot.team.join_usage")))
+	}
+	return bot.startJoinTeam(update, code)
+}
+
+// startJoinTeam Start team join dialog
+func (bot *Bot) startJoinTeam(update *tgbotapi.Update, code string) error {
+	team, err := storage.GetTeamByInviteCode(code)
+	if err != nil {
+		log.Errorf("join team: unable to find team: %s", err)
+		return bot.msg(update, esc(bot.t(update, "bot.errors.generic")))
+	}
+	if team == nil {
+		return bot.msg(update, esc(bot.t(update, "bot.team.invite_not_found")))
+	}
+
+	state := &storage.DialogState{
+		ParticipantId: update.FromChat().ID,
+		DialogType:    DialogTypeJoinTeam,
+		DialogStep:    JoinTeamStepZero,
+		Values: storage.DialogValues{
+			joinTeamValueTeamId: team.Id,
+		},
+	}
+	if _, _, err := joinableTeam(state); err != nil {
+		return bot.joinTeamError(update, err)
+	}
+	return bot.processDialog(update, state)
+}
+
+var joinTeamSteps = map[string]DialogAction{
+	JoinTeamStepZero: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		team, contest, err := joinableTeam(state)
+		if err != nil {
+			return true, bot.joinTeamError(update, err)
+		}
+
+		lang := bot.language(update)
+		message := strings.Builder{}
+		message.WriteString(bold(contest.Name) + "\n")
+		message.WriteString(esc(i18n.T(lang, "bot.team.joining", team.Name)) + "\n")
+		message.WriteString(teamMembers(lang, team) + "\n")
+		if bot.consentRequired() {
+			state.DialogStep = JoinTeamStepConsent
+			return false, bot.askConsent(update, message.String())
+		}
+		message.WriteString(esc(i18n.T(lang, "bot.team.name_prompt")))
+
+		state.DialogStep = JoinTeamStepName
+		return false, bot.msg(update, message.String())
+	},
+
+	JoinTeamStepConsent: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		accepted, done, err := bot.consentAnswer(update, state)
+		if !accepted {
+			return done, err
+		}
+
+		state.DialogStep = JoinTeamStepName
+		return false, bot.msg(update, esc(bot.t(update, "bot.team.name_prompt")))
+	},
+
+	JoinTeamStepName: func(bot *Bot, update *tgbotapi.Update, state *storage.DialogState) (bool, error) {
+		if update.Message == nil {
+			return false, nil
+		}
+
+		team, contest, err := joinableTeam(state)
+		if err != nil {
+			return true, bot.joinTeamError(update, err)
+		}
+
+		name := trim(update.Message.Text, maxMemberNameLength)
+		if len(name) == 0 {
+			return false, bot.msg(update, esc(bot.t(update, "bot.registration.name_example")))
+		}
+
+		consentAt, consentVersion := consentValues(state)
+		team, err = storage.JoinTeam(team.Id, storage.TeamMember{
+			ChatId:         state.ParticipantId,
+			Name:           name,
+			ConsentAt:      consentAt,
+			ConsentVersion: consentVersion,
+		})
+		if err != nil {
+			return true, bot.joinTeamError(update, err)
+		}
+		webhooks.ParticipantUpdated(team)
+
+		if team.ParticipantId != 0 {
+			lang := chatLanguage(team.ParticipantId)
+			notification := esc(i18n.T(lang, "bot.team.joined_captain", name, team.Name)) + "\n" + teamMembers(lang, team)
+			if err := bot.send(team.ParticipantId, notification); err != nil {
+				log.Errorf("join team: unable to notify captain %d: %s", team.ParticipantId, err)
+			}
+		}
+
+		lang := bot.language(update)
+		message := strings.Builder{}
+		message.WriteString(esc(i18n.T(lang, "bot.team.joined", team.Name, contest.Name)) + "\n")
+		if team.Waitlisted {
+			message.WriteString(esc(i18n.T(lang, "bot.team.waitlisted")) + "\n")
+		} else {
+			message.WriteString(esc(i18n.T(lang, "bot.team.shared_credentials")) + "\n")
+			message.WriteString(credentials(lang, team))
+		}
+		message.WriteString("\n" + esc(i18n.T(lang, "bot.team.contests_hint")))
+		return true, bot.msg(update, message.String())
+	},
+}
+
+// joinableTeam Team from the dialog state open for new members
+func joinableTeam(state *storage.DialogState) (*storage.ContestParticipant, *storage.Contest, error) {
+	teamId, _ := state.Values[joinTeamValueTeamId].(uint64)
+	team, err := storage.GetContestParticipant(teamId)
+	if err != nil {
+		return nil, nil, storage.ErrTeamNotFound
+	}
+	contest, err := storage.GetContest(team.ContestId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contest.Hidden || !contest.TeamMode {
+		return nil, nil, storage.ErrTeamNotFound
+	}
+	if !contest.RegistrationOpen(time.Now()) {
+		return nil, nil, errTeamClosed
+	}
+	if team.HasMember(state.ParticipantId) {
+		return nil, nil, storage.ErrAlreadyRegistered
+	}
+	if len(team.Members) >= contest.MaxMembers() {
+		return nil, nil, storage.ErrTeamFull
+	}
+	return team, contest, nil
+}
+
+// joinTeamError Report why the team can not be joined
+func (bot *Bot) joinTeamError(update *tgbotapi.Update, err error) error {
+	switch err {
+	case storage.ErrTeamNotFound:
+		return bot.msg(update, esc(bot.t(update, "bot.team.not_found")))
+	case storage.ErrTeamFull:
+		return bot.msg(update, esc(bot.t(update, "bot.team.full")))
+	case storage.ErrAlreadyRegistered:
+		return bot.msg(update, esc(bot.t(update, "bot.team.already_registered")))
+	case errTeamClosed:
+		return bot.msg(update, esc(bot.t(update, "bot.team.closed")))
+	}
+	log.Errorf("join team error: %s", err)
+	return bot.msg(update, esc(bot.t(update, "bot.errors.generic")))
+}
+
+// callbackLeaveTeam Remove team member, captain withdraws the whole team instead
+func (bot *Bot) callbackLeaveTeam(update *tgbotapi.Update, teamId uint64) error {
+	chatId := update.FromChat().ID
+
+	team, err := storage.GetContestParticipant(teamId)
+	if err != nil || !team.HasMember(chatId) {
+		return bot.registrationError(update, errRegistrationNotFound)
+	}
+	contest, err := storage.GetContest(team.ContestId)
+	if err != nil {
+		return bot.registrationError(update, err)
+	}
+	if !contest.RegistrationOpen(time.Now()) {
+		return bot.registrationError(update, errRegistrationClosed)
+	}
+
+	team, err = storage.LeaveTeam(team.Id, chatId)
+	if err == storage.ErrCaptainCannotLeave {
+		return bot.msg(update, esc(bot.t(update, "bot.team.captain_cannot_leave")))
+	}
+	if err != nil {
+		log.Errorf("leave team: unable to remove %d from team %d: %s", chatId, teamId, err)
+		return bot.msg(update, esc(bot.t(update, "bot.team.leave_failed")))
+	}
+	webhooks.ParticipantUpdated(team)
+
+	if team.ParticipantId != 0 {
+		lang := chatLanguage(team.ParticipantId)
+		notification := esc(i18n.T(lang, "bot.team.left_captain", team.Name)) + "\n" + teamMembers(lang, team)
+		if err := bot.send(team.ParticipantId, notification); err != nil {
+			log.Errorf("leave team: unable to notify captain %d: %s", team.ParticipantId, err)
+		}
+	}
+
+	return bot.msg(update, esc(bot.t(update, "bot.team.left", team.Name)))
+}
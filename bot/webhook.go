@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+var webhookSecretRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// WebhookEnabled Bot receives updates with webhook instead of long polling
+func (bot *Bot) WebhookEnabled() bool {
+	return bot.config.Mode == ModeWebhook
+}
+
+// WebhookPath Path of the webhook endpoint on the web server
+func (bot *Bot) WebhookPath() string {
+	return bot.config.WebhookPath
+}
+
+// WebhookHandler HTTP handler receiving updates from Telegram
+func (bot *Bot) WebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := r.Header.Get(webhookSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(bot.config.WebhookSecret)) != 1 {
+			log.Warnf("webhook request with wrong secret token from %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		update, err := bot.api.HandleUpdate(r)
+		if err != nil {
+			log.Errorf("unable to read webhook update: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		bot.updates <- *update
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (bot *Bot) setWebhook() error {
+	params := tgbotapi.Params{}
+	params["url"] = bot.config.WebhookURL
+	params["secret_token"] = bot.config.WebhookSecret
+	if _, err := bot.api.MakeRequest("setWebhook", params); err != nil {
+		return err
+	}
+	log.Infof("webhook set to %s", bot.config.WebhookURL)
+	return nil
+}
+
+func (bot *Bot) deleteWebhook() error {
+	if _, err := bot.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return err
+	}
+	log.Info("webhook deleted")
+	return nil
+}
+
+func checkWebhookConfiguration(config *Configuration) error {
+	if config.WebhookPath == "" {
+		config.WebhookPath = defaultWebhookPath
+	}
+
+	webhookURL, err := url.Parse(config.WebhookURL)
+	if err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+		return errors.New("bot webhook URL must be absolute https URL")
+	}
+	if !webhookSecretRegexp.MatchString(config.WebhookSecret) {
+		return errors.New("bot webhook secret must be 1-256 characters: A-Z, a-z, 0-9, _ and -")
+	}
+
+	return nil
+}
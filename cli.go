@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"contest-registration-bot/storage"
+	"errors"
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"strings"
+)
+
+const usage = `Usage:
+  app                                start bot and web server
+  app user list                      list admin users
+  app user add <login> <role>        create admin user, roles: organizer, volunteer
+  app user password <login>          change admin user password
+  app user role <login> <role>       change admin user role
+  app user delete <login>            delete admin user
+  app migrate [-dry-run]             apply pending database migrations,
+                                     -dry-run shows changes without saving them
+  app backup <file>                  save database copy, "-" writes to stdout
+  app export <file>                  save JSON export of contests and registrations, "-" writes to stdout
+  app import [-replace] <file>       load JSON export, "-" reads from stdin,
+                                     -replace deletes current contests and registrations
+
+Commands other than app itself need the app to be stopped, the running app locks the database.
+While it is running, use backup and export on the admin panel data page.`
+
+var errUsage = errors.New("invalid command")
+
+// runCommand Execute CLI subcommand
+func runCommand(args []string) error {
+	switch args[0] {
+	case "user":
+		return userCommand(args[1:])
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "backup":
+		return backupCommand(args[1:])
+	case "export":
+		return exportCommand(args[1:])
+	case "import":
+		return importCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return errUsage
+	}
+}
+
+func userCommand(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		users, err := storage.GetUsers()
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			fmt.Printf("%s\t%s\n", user.Login, user.Role)
+		}
+		return nil
+
+	case args[0] == "add" && len(args) == 3:
+		user := &storage.User{
+			Login: args[1],
+			Role:  args[2],
+		}
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		if err := user.SetPassword(password); err != nil {
+			return err
+		}
+		if err := storage.SaveUser(user); err != nil {
+			return err
+		}
+		fmt.Printf("user %s created\n", user.Login)
+		return nil
+
+	case args[0] == "password" && len(args) == 2:
+		user, err := cliUser(args[1])
+		if err != nil {
+			return err
+		}
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		if err := user.SetPassword(password); err != nil {
+			return err
+		}
+		if err := storage.SaveUser(user); err != nil {
+			return err
+		}
+		fmt.Printf("user %s password changed\n", user.Login)
+		return nil
+
+	case args[0] == "role" && len(args) == 3:
+		user, err := cliUser(args[1])
+		if err != nil {
+			return err
+		}
+		user.Role = args[2]
+		if err := storage.SaveUser(user); err != nil {
+			return err
+		}
+		fmt.Printf("user %s role changed to %s\n", user.Login, user.Role)
+		return nil
+
+	case args[0] == "delete" && len(args) == 2:
+		user, err := cliUser(args[1])
+		if err != nil {
+			return err
+		}
+		if err := storage.DeleteUser(user.Id); err != nil {
+			return err
+		}
+		fmt.Printf("user %s deleted\n", user.Login)
+		return nil
+
+	default:
+		return errUsage
+	}
+}
+
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version: %d, latest: %d\n", version, storage.LatestSchemaVersion())
+
+	results, err := storage.Migrate(*dryRun)
+	for _, result := range results {
+		fmt.Printf("migration %d: %s, records changed: %d\n", result.Version, result.Description, result.Changed)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(results) == 0:
+		fmt.Println("no pending migrations")
+	case *dryRun:
+		fmt.Println("dry run, no changes saved")
+	default:
+		fmt.Println("migrations applied")
+	}
+	return nil
+}
+
+func backupCommand(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return writeOutput(args[0], func(w io.Writer) error {
+		_, err := storage.WriteBackup(w)
+		return err
+	})
+}
+
+func exportCommand(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return writeOutput(args[0], storage.WriteExport)
+}
+
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	replace := flags.Bool("replace", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	input := os.Stdin
+	if fileName := flags.Arg(0); fileName != "-" {
+		file, err := os.Open(fileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	result, err := storage.ReadExport(input, *replace)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d contests, %d participants, %d notifications, %d dialog states\n",
+		result.Contests, result.Participants, result.Notifications, result.DialogStates)
+	return nil
+}
+
+// writeOutput Write to file or to stdout when file name is "-"
+func writeOutput(fileName string, write func(w io.Writer) error) error {
+	if fileName == "-" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		os.Remove(fileName)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "saved to %s\n", fileName)
+	return nil
+}
+
+// migrateStorage Apply pending migrations on startup
+func migrateStorage() error {
+	results, err := storage.Migrate(false)
+	for _, result := range results {
+		log.Infof("storage migration %d applied: %s, records changed: %d", result.Version, result.Description, result.Changed)
+	}
+	return err
+}
+
+func cliUser(login string) (*storage.User, error) {
+	user, err := storage.GetUserByLogin(login)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %s not found", login)
+	}
+	return user, nil
+}
+
+// readPassword Read new password from standard input
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(password) == 0 {
+		return "", err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters long")
+	}
+	return password, nil
+}
@@ -0,0 +1,129 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+	"path"
+	"strings"
+)
+
+const (
+	// DefaultLanguage Language of users without known language
+	DefaultLanguage = "ru"
+	// FallbackLanguage Language of users with language not supported by catalogs
+	FallbackLanguage = "en"
+)
+
+//go:embed locales/*.yaml
+var locales embed.FS
+
+// languages Supported languages, the first one is default
+var languages = []string{DefaultLanguage, FallbackLanguage}
+
+var (
+	catalogs = make(map[string]map[string]string)
+	matcher  language.Matcher
+)
+
+func init() {
+	var tags []language.Tag
+	for _, lang := range languages {
+		catalog, err := loadCatalog(lang)
+		if err != nil {
+			panic(fmt.Sprintf("unable to load %s message catalog: %s", lang, err))
+		}
+		catalogs[lang] = catalog
+		tags = append(tags, language.Make(lang))
+	}
+	matcher = language.NewMatcher(tags)
+
+	for _, lang := range languages[1:] {
+		for key := range catalogs[DefaultLanguage] {
+			if _, ok := catalogs[lang][key]; !ok {
+				log.Warnf("message %s is missing in %s catalog", key, lang)
+			}
+		}
+	}
+}
+
+// loadCatalog Read messages of language, nested keys are joined with dots
+func loadCatalog(lang string) (map[string]string, error) {
+	data, err := locales.ReadFile(path.Join("locales", lang+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	catalog := make(map[string]string)
+	if err := flatten(catalog, "", tree); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+func flatten(catalog map[string]string, prefix string, tree map[string]interface{}) error {
+	for key, value := range tree {
+		if len(prefix) != 0 {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case string:
+			catalog[key] = value
+		case map[string]interface{}:
+			if err := flatten(catalog, key, value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s is not a string", key)
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Languages Supported languages
+func Languages() []string {
+	return languages
+}
+
+// Supported Language has message catalog
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Language Supported language best matching Telegram language code or Accept-Language header,
+// empty value gives default language, unknown languages get fallback language
+func Language(code string) string {
+	tags, _, err := language.ParseAcceptLanguage(strings.TrimSpace(code))
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return FallbackLanguage
+	}
+	return languages[index]
+}
+
+// T Message of language by key formatted with args,
+// messages missing in catalog are taken from default language
+func T(lang, key string, args ...interface{}) string {
+	message, ok := catalogs[lang][key]
+	if !ok {
+		message, ok = catalogs[DefaultLanguage][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
@@ -2,16 +2,29 @@ package main
 
 import (
 	"contest-registration-bot/bot"
+	"contest-registration-bot/scheduler"
 	"contest-registration-bot/storage"
 	"contest-registration-bot/web"
+	"contest-registration-bot/webhooks"
+	"context"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 var (
-	webConfiguration web.Configuration
-	botConfiguration bot.Configuration
+	webConfiguration       web.Configuration
+	botConfiguration       bot.Configuration
+	schedulerConfiguration scheduler.Configuration
+	webhooksConfiguration  webhooks.Configuration
 )
 
 func init() {
@@ -28,18 +41,38 @@ func init() {
 		log.Fatalf("Unable to read config file: %s", err)
 	}
 	webConfiguration = web.Configuration{
-		DebugTemplates: false,
-		Listen:         ":3000",
+		DebugTemplates:  false,
+		Listen:          ":3000",
+		SessionLifetime: 7 * 24 * time.Hour,
+		SecureCookies:   false,
 	}
 	if err := viper.UnmarshalKey("web", &webConfiguration); err != nil {
 		log.Fatalf("Unable to read web configuration: %s", err)
 	}
 	botConfiguration = bot.Configuration{
 		Debug: false,
+		Mode:  bot.ModePolling,
 	}
 	if err := viper.UnmarshalKey("bot", &botConfiguration); err != nil {
 		log.Fatalf("Unable to read bot configuration: %s", err)
 	}
+	schedulerConfiguration = scheduler.Configuration{
+		Interval:       30 * time.Second,
+		BackupDir:      "data/backups",
+		BackupInterval: 24 * time.Hour,
+		BackupKeep:     7,
+	}
+	if err := viper.UnmarshalKey("scheduler", &schedulerConfiguration); err != nil {
+		log.Fatalf("Unable to read scheduler configuration: %s", err)
+	}
+	webhooksConfiguration = webhooks.Configuration{
+		Interval: 30 * time.Second,
+		Timeout:  10 * time.Second,
+		KeepLog:  30 * 24 * time.Hour,
+	}
+	if err := viper.UnmarshalKey("webhooks", &webhooksConfiguration); err != nil {
+		log.Fatalf("Unable to read webhooks configuration: %s", err)
+	}
 }
 
 func main() {
@@ -48,12 +81,64 @@ func main() {
 	}
 	defer storage.Close()
 
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err == errUsage {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := migrateStorage(); err != nil {
+		log.Fatalf("unable to migrate storage: %s", err)
+	}
+
+	if users, err := storage.GetUsers(); err != nil {
+		log.Fatalf("unable to get admin users: %s", err)
+	} else if len(users) == 0 {
+		log.Warn("no admin users found, create one with: app user add <login> organizer")
+	}
+	if err := storage.DeleteExpiredSessions(); err != nil {
+		log.Errorf("unable to delete expired sessions: %s", err)
+	}
+
 	registrationBot, err := bot.New(botConfiguration)
 	if err != nil {
 		log.Fatalf("unable to create bot: %s", err)
 	}
-	registrationBot.Start()
 
 	server := web.NewServer(webConfiguration, registrationBot)
-	log.Fatal(server.Start(webConfiguration.Listen))
+	go func() {
+		if err := server.Start(webConfiguration.Listen); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("web server error: %s", err)
+		}
+	}()
+
+	if err := registrationBot.Start(); err != nil {
+		log.Fatalf("unable to start bot: %s", err)
+	}
+
+	jobScheduler := scheduler.New(schedulerConfiguration, registrationBot)
+	jobScheduler.Start()
+
+	webhookDispatcher := webhooks.New(webhooksConfiguration)
+	webhookDispatcher.Start()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	log.Info("shutting down")
+
+	jobScheduler.Stop()
+	webhookDispatcher.Stop()
+	registrationBot.Stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Errorf("web server shutdown error: %s", err)
+	}
 }
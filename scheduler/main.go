@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"contest-registration-bot/bot"
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const defaultInterval = 30 * time.Second
+
+type Configuration struct {
+	Interval time.Duration
+	// BackupDir Directory of automatic database snapshots
+	BackupDir string
+	// BackupInterval How often to take snapshots, zero disables snapshots
+	BackupInterval time.Duration
+	// BackupKeep How many newest snapshots to keep
+	BackupKeep int
+}
+
+type Scheduler struct {
+	bot        *bot.Bot
+	config     Configuration
+	stop       chan struct{}
+	done       chan struct{}
+	lastBackup time.Time
+}
+
+// New Create new scheduler of delayed jobs
+func New(config Configuration, b *bot.Bot) *Scheduler {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	return &Scheduler{
+		bot:    b,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start Run due jobs periodically in background
+func (scheduler *Scheduler) Start() {
+	if scheduler.backupEnabled() {
+		snapshots, err := storage.GetSnapshots(scheduler.config.BackupDir)
+		if err != nil {
+			log.Errorf("scheduler: unable to list snapshots: %s", err)
+		} else if len(snapshots) != 0 {
+			scheduler.lastBackup = snapshots[0].CreatedAt
+		}
+	}
+
+	go func() {
+		defer close(scheduler.done)
+
+		ticker := time.NewTicker(scheduler.config.Interval)
+		defer ticker.Stop()
+
+		scheduler.run()
+
+		for {
+			select {
+			case <-scheduler.stop:
+				return
+			case <-ticker.C:
+				scheduler.run()
+			}
+		}
+	}()
+}
+
+// Stop Wait for current jobs and stop scheduler
+func (scheduler *Scheduler) Stop() {
+	close(scheduler.stop)
+	<-scheduler.done
+}
+
+func (scheduler *Scheduler) run() {
+	now := time.Now()
+	scheduler.closeExpiredContests(now)
+	scheduler.sendDueNotifications(now)
+	scheduler.backup(now)
+}
+
+func (scheduler *Scheduler) backupEnabled() bool {
+	return scheduler.config.BackupInterval > 0 && len(scheduler.config.BackupDir) != 0
+}
+
+// backup Take database snapshot and delete old ones
+func (scheduler *Scheduler) backup(now time.Time) {
+	if !scheduler.backupEnabled() || now.Sub(scheduler.lastBackup) < scheduler.config.BackupInterval {
+		return
+	}
+
+	snapshot, err := storage.CreateSnapshot(scheduler.config.BackupDir, now)
+	if err != nil {
+		log.Errorf("scheduler: unable to create database snapshot: %s", err)
+		return
+	}
+	scheduler.lastBackup = now
+	log.Infof("scheduler: database snapshot saved to %s", snapshot.FileName)
+
+	deleted, err := storage.PruneSnapshots(scheduler.config.BackupDir, scheduler.config.BackupKeep)
+	if err != nil {
+		log.Errorf("scheduler: unable to delete old snapshots: %s", err)
+	}
+	for _, snapshot := range deleted {
+		log.Infof("scheduler: old database snapshot %s deleted", snapshot.FileName)
+	}
+}
+
+// closeExpiredContests Close registration after registration deadline
+func (scheduler *Scheduler) closeExpiredContests(now time.Time) {
+	contests, err := storage.CloseExpiredContests(now)
+	if err != nil {
+		log.Errorf("scheduler: unable to close expired contests: %s", err)
+		return
+	}
+	for _, contest := range contests {
+		log.Infof("scheduler: registration deadline of contest %d passed, registration closed", contest.Id)
+		webhooks.ContestClosed(&contest)
+	}
+}
+
+// sendDueNotifications Send scheduled notifications and reminders
+func (scheduler *Scheduler) sendDueNotifications(now time.Time) {
+	notifications, err := storage.GetDueNotifications(now)
+	if err != nil {
+		log.Errorf("scheduler: unable to get due notifications: %s", err)
+		return
+	}
+
+	for _, notification := range notifications {
+		log.Infof("scheduler: sending notification %d of contest %d", notification.Id, notification.ContestId)
+
+		//notification is marked sent when its deliveries are created
+		if err := scheduler.bot.SendNotifications(&notification); err != nil {
+			log.Errorf("scheduler: unable to send notification %d: %s", notification.Id, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotPrefix     = "bolt-"
+	snapshotSuffix     = ".db"
+	snapshotTimeFormat = "20060102-150405"
+)
+
+// Snapshot Automatic database snapshot file
+type Snapshot struct {
+	FileName  string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// WriteBackup Write consistent copy of the whole database, safe while the app is running
+func WriteBackup(w io.Writer) (int64, error) {
+	var size int64
+	err := store.Bolt().View(func(tx *bolt.Tx) error {
+		var err error
+		size, err = tx.WriteTo(w)
+		return err
+	})
+	return size, err
+}
+
+// BackupSize Size of the database backup in bytes
+func BackupSize() (int64, error) {
+	var size int64
+	err := store.Bolt().View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	return size, err
+}
+
+// CreateSnapshot Save database copy into directory
+func CreateSnapshot(dir string, now time.Time) (*Snapshot, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	fileName := filepath.Join(dir, snapshotPrefix+now.Format(snapshotTimeFormat)+snapshotSuffix)
+	err := store.Bolt().View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(fileName, 0600)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		FileName:  fileName,
+		CreatedAt: now,
+		Size:      info.Size(),
+	}, nil
+}
+
+// GetSnapshots List database snapshots in directory, newest first
+func GetSnapshots(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(snapshotTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{
+			FileName:  filepath.Join(dir, name),
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+
+	return snapshots, nil
+}
+
+// PruneSnapshots Delete all snapshots except given number of the newest ones, zero keeps all
+func PruneSnapshots(dir string, keep int) ([]Snapshot, error) {
+	snapshots, err := GetSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+	if keep <= 0 || len(snapshots) <= keep {
+		return nil, nil
+	}
+
+	deleted := snapshots[keep:]
+	for _, snapshot := range deleted {
+		if err := os.Remove(snapshot.FileName); err != nil {
+			return nil, fmt.Errorf("unable to delete snapshot %s: %s", snapshot.FileName, err)
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+	"time"
+)
+
+// ContestTimezones Suggested contest timezones
+func ContestTimezones() []string {
+	return []string{
+		"Europe/Kaliningrad",
+		"Europe/Moscow",
+		"Europe/Samara",
+		"Asia/Yekaterinburg",
+		"Asia/Omsk",
+		"Asia/Novosibirsk",
+		"Asia/Krasnoyarsk",
+		"Asia/Irkutsk",
+		"Asia/Yakutsk",
+		"Asia/Vladivostok",
+		"Asia/Magadan",
+		"Asia/Kamchatka",
+		"UTC",
+	}
+}
+
+// Location Contest timezone, server local time when timezone is not set or unknown
+func (contest Contest) Location() *time.Location {
+	if len(contest.Timezone) == 0 {
+		return time.Local
+	}
+	location, err := time.LoadLocation(contest.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// InLocation Time in contest timezone
+func (contest Contest) InLocation(t time.Time) time.Time {
+	return t.In(contest.Location())
+}
+
+// RegistrationOpen Contest accepts new registrations at given time
+func (contest Contest) RegistrationOpen(now time.Time) bool {
+	if contest.Closed {
+		return false
+	}
+	return contest.RegistrationDeadline.IsZero() || now.Before(contest.RegistrationDeadline)
+}
+
+// SortContestsByStart Order contests by start time, contests without start time go last
+func SortContestsByStart(contests []Contest) {
+	sort.SliceStable(contests, func(i, j int) bool {
+		a, b := contests[i].StartsAt, contests[j].StartsAt
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+}
+
+// CloseExpiredContests Close registration of contests with passed registration deadline
+func CloseExpiredContests(now time.Time) ([]Contest, error) {
+	var closed []Contest
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		var contests []Contest
+		if err := store.TxFind(tx, &contests, bolthold.Where("Closed").Eq(false)); err != nil {
+			return err
+		}
+		for _, contest := range contests {
+			if contest.RegistrationOpen(now) {
+				continue
+			}
+			contest.Closed = true
+			if err := store.TxUpdate(tx, contest.Id, &contest); err != nil {
+				return err
+			}
+			closed = append(closed, contest)
+		}
+		return nil
+	})
+
+	return closed, err
+}
+
+// DeleteContest Delete contest with all its participants, notifications and uploaded imports
+func DeleteContest(id uint64) error {
+	return store.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := store.TxDelete(tx, id, &Contest{}); err != nil {
+			return err
+		}
+		for _, dataType := range []interface{}{&ContestParticipant{}, &ContestNotification{}, &NotificationDelivery{}, &ParticipantImport{}} {
+			if err := store.TxDeleteMatching(tx, dataType, bolthold.Where("ContestId").Eq(id)); err != nil {
+				return err
+			}
+		}
+		//webhooks of all contests keep deliveries of the deleted contest
+		var webhooks []Webhook
+		if err := store.TxFind(tx, &webhooks, bolthold.Where("ContestId").Eq(id)); err != nil {
+			return err
+		}
+		for _, webhook := range webhooks {
+			if err := store.TxDeleteMatching(tx, &WebhookDelivery{}, bolthold.Where("WebhookId").Eq(webhook.Id)); err != nil {
+				return err
+			}
+			if err := store.TxDelete(tx, webhook.Id, &Webhook{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
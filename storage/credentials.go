@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"strings"
+)
+
+const (
+	PasswordAlphabetAlnum      = "alnum"
+	PasswordAlphabetLowerAlnum = "lower_alnum"
+	PasswordAlphabetLetters    = "letters"
+	PasswordAlphabetDigits     = "digits"
+
+	lowerLetters = "abcdefghijklmnopqrstuvwxyz"
+	upperLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits       = "0123456789"
+
+	// ambiguousCharacters Characters easily confused when credentials are printed or dictated
+	ambiguousCharacters = "0Oo1lI"
+
+	// loginAlphabet Random part of logins, case-insensitive systems must accept them too
+	loginAlphabet = lowerLetters + digits
+
+	maxCredentialAttempts = 100
+)
+
+// ErrLoginExists Participant login is not unique within the contest
+var ErrLoginExists = errors.New("login is already used in this contest")
+
+// PasswordAlphabets All supported password alphabets
+func PasswordAlphabets() []string {
+	return []string{
+		PasswordAlphabetAlnum,
+		PasswordAlphabetLowerAlnum,
+		PasswordAlphabetLetters,
+		PasswordAlphabetDigits,
+	}
+}
+
+// DefaultCredentialPolicy Credential policy of contests without configured one
+func DefaultCredentialPolicy() CredentialPolicy {
+	return CredentialPolicy{
+		LoginPrefix:      "p_",
+		LoginLength:      6,
+		SequenceDigits:   3,
+		PasswordLength:   10,
+		PasswordAlphabet: PasswordAlphabetAlnum,
+		ExcludeAmbiguous: true,
+	}
+}
+
+// CredentialPolicy Contest credential policy, default one when not configured
+func (contest Contest) CredentialPolicy() CredentialPolicy {
+	if contest.Credentials.PasswordLength == 0 {
+		return DefaultCredentialPolicy()
+	}
+	return contest.Credentials
+}
+
+// Validate Check policy values
+func (policy *CredentialPolicy) Validate() error {
+	if strings.ContainsAny(policy.LoginPrefix, " \t\r\n;,") {
+		return errors.New("login prefix must not contain spaces, commas and semicolons")
+	}
+	if policy.Sequential {
+		if policy.SequenceDigits < 1 || policy.SequenceDigits > 9 {
+			return errors.New("login number digits must be from 1 to 9")
+		}
+	} else if policy.LoginLength < 4 || policy.LoginLength > 32 {
+		return errors.New("login length must be from 4 to 32")
+	}
+	if policy.PasswordLength < 6 || policy.PasswordLength > 64 {
+		return errors.New("password length must be from 6 to 64")
+	}
+	if len(policy.alphabet()) == 0 {
+		return fmt.Errorf("unknown password alphabet: %s", policy.PasswordAlphabet)
+	}
+	return nil
+}
+
+// alphabet Characters of generated passwords
+func (policy *CredentialPolicy) alphabet() string {
+	var alphabet string
+	switch policy.PasswordAlphabet {
+	case PasswordAlphabetAlnum:
+		alphabet = lowerLetters + upperLetters + digits
+	case PasswordAlphabetLowerAlnum:
+		alphabet = lowerLetters + digits
+	case PasswordAlphabetLetters:
+		alphabet = lowerLetters
+	case PasswordAlphabetDigits:
+		alphabet = digits
+	default:
+		return ""
+	}
+	return policy.filter(alphabet)
+}
+
+// filter Remove ambiguous characters from alphabet when policy requires
+func (policy *CredentialPolicy) filter(alphabet string) string {
+	if !policy.ExcludeAmbiguous {
+		return alphabet
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(ambiguousCharacters, r) {
+			return -1
+		}
+		return r
+	}, alphabet)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// txGenerateCredentials Fill empty login and password of registered participant
+// according to contest credential policy, check login uniqueness within contest.
+// Teams get invite code even in the waitlist
+func txGenerateCredentials(tx *bolt.Tx, contest *Contest, participant *ContestParticipant) error {
+	if err := txGenerateInviteCode(tx, contest, participant); err != nil {
+		return err
+	}
+
+	if participant.Waitlisted {
+		return nil
+	}
+
+	policy := contest.CredentialPolicy()
+
+	if len(participant.Password) == 0 {
+		password, err := randomString(policy.alphabet(), policy.PasswordLength)
+		if err != nil {
+			return err
+		}
+		participant.Password = password
+	}
+
+	if len(participant.Login) != 0 {
+		exists, err := txLoginExists(tx, participant)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: %s", ErrLoginExists, participant.Login)
+		}
+		return nil
+	}
+
+	for attempt := 0; attempt < maxCredentialAttempts; attempt++ {
+		if policy.Sequential {
+			contest.LoginSequence++
+			participant.Login = fmt.Sprintf("%s%0*d", policy.LoginPrefix, policy.SequenceDigits, contest.LoginSequence)
+		} else {
+			login, err := randomString(policy.filter(loginAlphabet), policy.LoginLength)
+			if err != nil {
+				return err
+			}
+			participant.Login = policy.LoginPrefix + login
+		}
+
+		exists, err := txLoginExists(tx, participant)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if policy.Sequential {
+				return store.TxUpdate(tx, contest.Id, contest)
+			}
+			return nil
+		}
+	}
+
+	participant.Login = ""
+	return errors.New("unable to generate unique login, change contest credential policy")
+}
+
+// txLoginExists Other participant of the same contest has the same login
+func txLoginExists(tx *bolt.Tx, participant *ContestParticipant) (bool, error) {
+	count, err := store.TxCount(tx, &ContestParticipant{}, bolthold.
+		Where("ContestId").Eq(participant.ContestId).
+		And("Login").Eq(participant.Login).
+		And(bolthold.Key).Ne(participant.Id))
+	if err != nil {
+		return false, err
+	}
+	return count != 0, nil
+}
+
+// randomString Cryptographically secure random string of alphabet characters
+func randomString(alphabet string, length int) (string, error) {
+	runes := []rune(alphabet)
+	if len(runes) == 0 {
+		return "", errors.New("empty alphabet")
+	}
+
+	max := big.NewInt(int64(len(runes)))
+	str := strings.Builder{}
+
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		str.WriteRune(runes[n.Int64()])
+	}
+
+	return str.String(), nil
+}
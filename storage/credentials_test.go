@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"strings"
+	"testing"
+)
+
+func TestCredentialPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy CredentialPolicy
+		valid  bool
+	}{
+		{"default", DefaultCredentialPolicy(), true},
+		{"sequential", CredentialPolicy{Sequential: true, SequenceDigits: 4, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetDigits}, true},
+		{"prefix with space", CredentialPolicy{LoginPrefix: "p ", LoginLength: 6, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"prefix with semicolon", CredentialPolicy{LoginPrefix: "p;", LoginLength: 6, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"short login", CredentialPolicy{LoginLength: 3, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"long login", CredentialPolicy{LoginLength: 33, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"no sequence digits", CredentialPolicy{Sequential: true, SequenceDigits: 0, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"too many sequence digits", CredentialPolicy{Sequential: true, SequenceDigits: 10, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"short password", CredentialPolicy{LoginLength: 6, PasswordLength: 5, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"long password", CredentialPolicy{LoginLength: 6, PasswordLength: 65, PasswordAlphabet: PasswordAlphabetAlnum}, false},
+		{"unknown alphabet", CredentialPolicy{LoginLength: 6, PasswordLength: 8, PasswordAlphabet: "emoji"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Validate()
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("policy accepted")
+			}
+		})
+	}
+}
+
+func TestCredentialPolicyAlphabet(t *testing.T) {
+	tests := []struct {
+		alphabet         string
+		excludeAmbiguous bool
+		want             string
+	}{
+		{PasswordAlphabetDigits, false, "0123456789"},
+		{PasswordAlphabetDigits, true, "23456789"},
+		{PasswordAlphabetLetters, true, "abcdefghijkmnpqrstuvwxyz"},
+		{PasswordAlphabetLowerAlnum, true, "abcdefghijkmnpqrstuvwxyz23456789"},
+		{PasswordAlphabetAlnum, false, lowerLetters + upperLetters + digits},
+		{"unknown", false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%t", test.alphabet, test.excludeAmbiguous), func(t *testing.T) {
+			policy := CredentialPolicy{PasswordAlphabet: test.alphabet, ExcludeAmbiguous: test.excludeAmbiguous}
+			if got := policy.alphabet(); got != test.want {
+				t.Errorf("alphabet() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      CredentialPolicy
+		participant ContestParticipant
+		check       func(t *testing.T, participant *ContestParticipant)
+	}{
+		{
+			name:   "random login without ambiguous characters",
+			policy: DefaultCredentialPolicy(),
+			check: func(t *testing.T, participant *ContestParticipant) {
+				login, ok := strings.CutPrefix(participant.Login, "p_")
+				if !ok || len(login) != 6 {
+					t.Errorf("login %q does not match policy", participant.Login)
+				}
+				if len(participant.Password) != 10 {
+					t.Errorf("password %q does not match policy", participant.Password)
+				}
+				if strings.ContainsAny(login+participant.Password, ambiguousCharacters) {
+					t.Errorf("ambiguous characters in %q, %q", participant.Login, participant.Password)
+				}
+			},
+		},
+		{
+			name:   "digits password",
+			policy: CredentialPolicy{LoginLength: 8, PasswordLength: 12, PasswordAlphabet: PasswordAlphabetDigits},
+			check: func(t *testing.T, participant *ContestParticipant) {
+				if len(participant.Login) != 8 {
+					t.Errorf("login %q does not match policy", participant.Login)
+				}
+				if len(participant.Password) != 12 || strings.Trim(participant.Password, digits) != "" {
+					t.Errorf("password %q does not match policy", participant.Password)
+				}
+			},
+		},
+		{
+			name:        "given credentials are kept",
+			policy:      DefaultCredentialPolicy(),
+			participant: ContestParticipant{Login: "team01", Password: "secret"},
+			check: func(t *testing.T, participant *ContestParticipant) {
+				if participant.Login != "team01" || participant.Password != "secret" {
+					t.Errorf("credentials changed to %q, %q", participant.Login, participant.Password)
+				}
+			},
+		},
+		{
+			name:        "waitlist gets no credentials",
+			policy:      DefaultCredentialPolicy(),
+			participant: ContestParticipant{Waitlisted: true},
+			check: func(t *testing.T, participant *ContestParticipant) {
+				if participant.Login != "" || participant.Password != "" {
+					t.Errorf("waitlisted participant got %q, %q", participant.Login, participant.Password)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			openTestStore(t)
+			contest := &Contest{Name: "contest", Credentials: test.policy}
+			if err := SaveContest(contest); err != nil {
+				t.Fatal(err)
+			}
+
+			participant := test.participant
+			participant.ContestId = contest.Id
+			err := store.Bolt().Update(func(tx *bolt.Tx) error {
+				return txGenerateCredentials(tx, contest, &participant)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			test.check(t, &participant)
+		})
+	}
+}
+
+func TestGenerateSequentialCredentials(t *testing.T) {
+	openTestStore(t)
+	contest := &Contest{
+		Name:        "contest",
+		Credentials: CredentialPolicy{LoginPrefix: "team", Sequential: true, SequenceDigits: 3, PasswordLength: 8, PasswordAlphabet: PasswordAlphabetAlnum},
+	}
+	if err := SaveContest(contest); err != nil {
+		t.Fatal(err)
+	}
+	//login of the next number is taken by hand
+	if err := store.Insert(bolthold.NextSequence(), &ContestParticipant{ContestId: contest.Id, Login: "team002"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var logins []string
+	for i := 0; i < 3; i++ {
+		participant := ContestParticipant{ContestId: contest.Id}
+		err := store.Bolt().Update(func(tx *bolt.Tx) error {
+			if err := txGenerateCredentials(tx, contest, &participant); err != nil {
+				return err
+			}
+			return store.TxInsert(tx, bolthold.NextSequence(), &participant)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		logins = append(logins, participant.Login)
+	}
+
+	if got, want := strings.Join(logins, ","), "team001,team003,team004"; got != want {
+		t.Errorf("logins = %s, want %s", got, want)
+	}
+	saved, err := GetContest(contest.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.LoginSequence != 4 {
+		t.Errorf("saved login sequence = %d, want 4", saved.LoginSequence)
+	}
+}
+
+func TestGenerateCredentialsLoginExists(t *testing.T) {
+	openTestStore(t)
+	contest := &Contest{Name: "contest"}
+	other := &Contest{Name: "other"}
+	for _, c := range []*Contest{contest, other} {
+		if err := SaveContest(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Insert(bolthold.NextSequence(), &ContestParticipant{ContestId: contest.Id, Login: "taken"}); err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(c *Contest) error {
+		participant := ContestParticipant{ContestId: c.Id, Login: "taken"}
+		return store.Bolt().Update(func(tx *bolt.Tx) error {
+			return txGenerateCredentials(tx, c, &participant)
+		})
+	}
+
+	if err := generate(contest); !errors.Is(err, ErrLoginExists) {
+		t.Errorf("error = %v, want %v", err, ErrLoginExists)
+	}
+	if err := generate(other); err != nil {
+		t.Errorf("login of other contest rejected: %s", err)
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+	"time"
+)
+
+// CreateNotificationDeliveries Mark the notification sent at given time and replace its deliveries
+// with pending deliveries to all registered contest participants, waitlist is skipped
+func CreateNotificationDeliveries(notification *ContestNotification, sentAt time.Time) ([]NotificationDelivery, error) {
+	var deliveries []NotificationDelivery
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		//notification is marked sent together with its deliveries,
+		//so it is never sent twice, interrupted deliveries are resumed on start
+		notification.SentAt = sentAt
+		if err := store.TxUpdate(tx, notification.Id, notification); err != nil {
+			return err
+		}
+
+		if err := store.TxDeleteMatching(tx, &NotificationDelivery{}, bolthold.Where("NotificationId").Eq(notification.Id)); err != nil {
+			return err
+		}
+
+		var participants []ContestParticipant
+		if err := store.TxFind(tx, &participants, bolthold.Where("ContestId").Eq(notification.ContestId)); err != nil {
+			return err
+		}
+		sort.Slice(participants, func(i, j int) bool {
+			return participants[i].Id < participants[j].Id
+		})
+
+		for _, participant := range participants {
+			if participant.Waitlisted {
+				continue
+			}
+			//every member of the team receives notification
+			for _, chatId := range participant.ChatIds() {
+				delivery := NotificationDelivery{
+					NotificationId:       notification.Id,
+					ContestId:            notification.ContestId,
+					ContestParticipantId: participant.Id,
+					ParticipantId:        chatId,
+					Status:               DeliveryStatusPending,
+					UpdatedAt:            time.Now(),
+				}
+				if err := store.TxInsert(tx, bolthold.NextSequence(), &delivery); err != nil {
+					return err
+				}
+				deliveries = append(deliveries, delivery)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// GetNotificationDeliveries List deliveries of the notification, ordered by id
+func GetNotificationDeliveries(notificationId uint64) ([]NotificationDelivery, error) {
+	var deliveries []NotificationDelivery
+	if err := store.Find(&deliveries, bolthold.Where("NotificationId").Eq(notificationId)); err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Id < deliveries[j].Id
+	})
+	return deliveries, nil
+}
+
+// GetPendingNotificationDeliveries List all not finished deliveries, ordered by id
+func GetPendingNotificationDeliveries() ([]NotificationDelivery, error) {
+	var deliveries []NotificationDelivery
+	if err := store.Find(&deliveries, bolthold.Where("Status").Eq(DeliveryStatusPending)); err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Id < deliveries[j].Id
+	})
+	return deliveries, nil
+}
+
+// SaveNotificationDelivery Update notification delivery
+func SaveNotificationDelivery(delivery *NotificationDelivery) error {
+	delivery.UpdatedAt = time.Now()
+	return store.Update(delivery.Id, delivery)
+}
+
+// DeleteNotificationDeliveries Remove all deliveries of the notification
+func DeleteNotificationDeliveries(notificationId uint64) error {
+	return store.DeleteMatching(&NotificationDelivery{}, bolthold.Where("NotificationId").Eq(notificationId))
+}
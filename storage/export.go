@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const exportFormatVersion = 1
+
+var errDataExists = errors.New("database already has contests, use replace to overwrite them")
+
+// Export Portable copy of all contests and registrations
+type Export struct {
+	FormatVersion int                   `json:"format_version"`
+	SchemaVersion int                   `json:"schema_version"`
+	ExportedAt    time.Time             `json:"exported_at"`
+	Contests      []Contest             `json:"contests"`
+	Participants  []ContestParticipant  `json:"participants"`
+	Notifications []ContestNotification `json:"notifications"`
+	DialogStates  []exportDialogState   `json:"dialog_states"`
+}
+
+// ImportResult Number of imported records
+type ImportResult struct {
+	Contests      int
+	Participants  int
+	Notifications int
+	DialogStates  int
+}
+
+// exportDialogState Dialog state with typed values, JSON numbers lose Go types otherwise
+type exportDialogState struct {
+	ParticipantId int64                  `json:"participant_id"`
+	DialogType    string                 `json:"dialog_type"`
+	DialogStep    string                 `json:"dialog_step"`
+	Values        map[string]exportValue `json:"values"`
+}
+
+type exportValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// WriteExport Write JSON export of contests, participants, notifications and dialog states
+func WriteExport(w io.Writer) error {
+	export := Export{
+		FormatVersion: exportFormatVersion,
+		ExportedAt:    time.Now(),
+	}
+
+	err := store.Bolt().View(func(tx *bolt.Tx) error {
+		export.SchemaVersion = txSchemaVersion(tx)
+
+		if err := store.TxFind(tx, &export.Contests, nil); err != nil {
+			return err
+		}
+		sort.Slice(export.Contests, func(i, j int) bool {
+			return export.Contests[i].Id < export.Contests[j].Id
+		})
+		if err := store.TxFind(tx, &export.Participants, nil); err != nil {
+			return err
+		}
+		sort.Slice(export.Participants, func(i, j int) bool {
+			return export.Participants[i].Id < export.Participants[j].Id
+		})
+		if err := store.TxFind(tx, &export.Notifications, nil); err != nil {
+			return err
+		}
+		sort.Slice(export.Notifications, func(i, j int) bool {
+			return export.Notifications[i].Id < export.Notifications[j].Id
+		})
+
+		var states []DialogState
+		if err := store.TxFind(tx, &states, nil); err != nil {
+			return err
+		}
+		for _, state := range states {
+			exported, err := exportState(state)
+			if err != nil {
+				return err
+			}
+			export.DialogStates = append(export.DialogStates, exported)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// ReadExport Import JSON export in one transaction, keeping record ids.
+// Existing contests, participants, notifications and dialog states are deleted when replace is set
+// together with records referencing their ids: delivery logs, participant imports, contest webhooks
+// and chat settings. Webhooks of all contests are kept
+func ReadExport(r io.Reader, replace bool) (*ImportResult, error) {
+	var export Export
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid export file: %s", err)
+	}
+	if export.FormatVersion != exportFormatVersion {
+		return nil, fmt.Errorf("unsupported export format version %d", export.FormatVersion)
+	}
+
+	result := &ImportResult{}
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		if version := txSchemaVersion(tx); export.SchemaVersion != version {
+			return fmt.Errorf("export schema version %d does not match database schema version %d", export.SchemaVersion, version)
+		}
+
+		count, err := store.TxCount(tx, &Contest{}, nil)
+		if err != nil {
+			return err
+		}
+		if count != 0 && !replace {
+			return errDataExists
+		}
+		if replace {
+			for _, dataType := range []interface{}{&Contest{}, &ContestParticipant{}, &ContestNotification{}, &NotificationDelivery{},
+				&DialogState{}, &ChatSettings{}, &WebhookDelivery{}, &ParticipantImport{}} {
+				if err := store.TxDeleteMatching(tx, dataType, nil); err != nil {
+					return err
+				}
+			}
+			//imported contests get ids of deleted ones, webhooks of a contest would receive events of another
+			if err := store.TxDeleteMatching(tx, &Webhook{}, bolthold.Where("ContestId").Ne(uint64(0))); err != nil {
+				return err
+			}
+		}
+
+		for _, contest := range export.Contests {
+			if err := store.TxInsert(tx, contest.Id, &contest); err != nil {
+				return fmt.Errorf("contest %d: %s", contest.Id, err)
+			}
+			result.Contests++
+		}
+		for _, participant := range export.Participants {
+			if err := store.TxInsert(tx, participant.Id, &participant); err != nil {
+				return fmt.Errorf("participant %d: %s", participant.Id, err)
+			}
+			result.Participants++
+		}
+		for _, notification := range export.Notifications {
+			if err := store.TxInsert(tx, notification.Id, &notification); err != nil {
+				return fmt.Errorf("notification %d: %s", notification.Id, err)
+			}
+			result.Notifications++
+		}
+		for _, exported := range export.DialogStates {
+			state, err := importState(exported)
+			if err != nil {
+				return err
+			}
+			if err := store.TxInsert(tx, state.ParticipantId, state); err != nil {
+				return fmt.Errorf("dialog state %d: %s", state.ParticipantId, err)
+			}
+			result.DialogStates++
+		}
+
+		//keep sequences ahead of imported ids, so new records get unique ids
+		sequences := map[string]uint64{}
+		for _, contest := range export.Contests {
+			sequences["Contest"] = max(sequences["Contest"], contest.Id)
+		}
+		for _, participant := range export.Participants {
+			sequences["ContestParticipant"] = max(sequences["ContestParticipant"], participant.Id)
+		}
+		for _, notification := range export.Notifications {
+			sequences["ContestNotification"] = max(sequences["ContestNotification"], notification.Id)
+		}
+		for bucketName, sequence := range sequences {
+			bucket := tx.Bucket([]byte(bucketName))
+			if bucket == nil || bucket.Sequence() >= sequence {
+				continue
+			}
+			if err := bucket.SetSequence(sequence); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func exportState(state DialogState) (exportDialogState, error) {
+	exported := exportDialogState{
+		ParticipantId: state.ParticipantId,
+		DialogType:    state.DialogType,
+		DialogStep:    state.DialogStep,
+		Values:        make(map[string]exportValue),
+	}
+
+	for key, value := range state.Values {
+		switch v := value.(type) {
+		case string:
+			exported.Values[key] = exportValue{Type: "string", Value: v}
+		case int:
+			exported.Values[key] = exportValue{Type: "int", Value: strconv.Itoa(v)}
+		case int64:
+			exported.Values[key] = exportValue{Type: "int64", Value: strconv.FormatInt(v, 10)}
+		case uint64:
+			exported.Values[key] = exportValue{Type: "uint64", Value: strconv.FormatUint(v, 10)}
+		case bool:
+			exported.Values[key] = exportValue{Type: "bool", Value: strconv.FormatBool(v)}
+		default:
+			return exported, fmt.Errorf("dialog state %d: unsupported value %s of type %T", state.ParticipantId, key, value)
+		}
+	}
+
+	return exported, nil
+}
+
+func importState(exported exportDialogState) (*DialogState, error) {
+	state := &DialogState{
+		ParticipantId: exported.ParticipantId,
+		DialogType:    exported.DialogType,
+		DialogStep:    exported.DialogStep,
+		Values:        make(DialogValues),
+	}
+
+	for key, value := range exported.Values {
+		var err error
+		switch value.Type {
+		case "string":
+			state.Values[key] = value.Value
+		case "int":
+			state.Values[key], err = strconv.Atoi(value.Value)
+		case "int64":
+			state.Values[key], err = strconv.ParseInt(value.Value, 10, 64)
+		case "uint64":
+			state.Values[key], err = strconv.ParseUint(value.Value, 10, 64)
+		case "bool":
+			state.Values[key], err = strconv.ParseBool(value.Value)
+		default:
+			err = fmt.Errorf("unsupported type %s", value.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dialog state %d, value %s: %s", state.ParticipantId, key, err)
+		}
+	}
+
+	return state, nil
+}
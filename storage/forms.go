@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"contest-registration-bot/i18n"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	phoneRegexp    = regexp.MustCompile(`^\+?[0-9()\- ]{5,20}$`)
+	nonDigitRegexp = regexp.MustCompile(`[^0-9]`)
+	//word of a name in one alphabet, parts of double names are joined with hyphen or apostrophe
+	nameWordRegexp = regexp.MustCompile(`^(\p{Cyrillic}+|\p{Latin}+)([-'’](\p{Cyrillic}+|\p{Latin}+))*$`)
+)
+
+// ValidationError Answer rejected by the form field, Message is a message key explaining expected answer
+type ValidationError struct {
+	Message string
+	Args    []interface{}
+}
+
+func (err *ValidationError) Error() string {
+	return err.Message
+}
+
+func validationError(message string, args ...interface{}) error {
+	return &ValidationError{Message: message, Args: args}
+}
+
+// FormFieldTypes All supported form field types
+func FormFieldTypes() []string {
+	return []string{FormFieldTypeText, FormFieldTypeNumber, FormFieldTypeEmail, FormFieldTypePhone, FormFieldTypeFullName, FormFieldTypeChoice}
+}
+
+// DefaultFormFields Registration form used by contests without own form, questions are in given language
+func DefaultFormFields(lang string) []FormField {
+	return []FormField{
+		defaultFormField(lang, FormFieldName, 100, true),
+		defaultFormField(lang, "school", 200, true),
+		defaultFormField(lang, FormFieldContacts, 100, true),
+		defaultFormField(lang, "languages", 200, false),
+	}
+}
+
+// defaultFormField Text question of the default form with texts from message catalog
+func defaultFormField(lang, key string, maxLength int, required bool) FormField {
+	return FormField{
+		Key:       key,
+		Title:     i18n.T(lang, "form.default."+key+".title"),
+		Prompt:    i18n.T(lang, "form.default."+key+".prompt"),
+		Example:   i18n.T(lang, "form.default."+key+".example"),
+		MaxLength: maxLength,
+		Required:  required,
+		Type:      FormFieldTypeText,
+	}
+}
+
+// FormFields Registration form of the contest
+func (contest *Contest) FormFields() []FormField {
+	//contests saved without own form use the form in default language
+	if len(contest.Fields) == 0 {
+		return DefaultFormFields(i18n.DefaultLanguage)
+	}
+	return contest.Fields
+}
+
+// FormField Find registration form field by key
+func (contest *Contest) FormField(key string) *FormField {
+	for _, field := range contest.FormFields() {
+		if field.Key == key {
+			return &field
+		}
+	}
+	return nil
+}
+
+// Answer Participant's answer to the registration form field
+func (participant *ContestParticipant) Answer(key string) string {
+	return participant.Answers[key]
+}
+
+// SetAnswer Save participant's answer to the registration form field
+func (participant *ContestParticipant) SetAnswer(key, value string) {
+	if participant.Answers == nil {
+		participant.Answers = make(map[string]string)
+	}
+	participant.Answers[key] = value
+	if key == FormFieldName {
+		participant.Name = value
+	}
+}
+
+// AcceptsContact Question can be answered by sharing Telegram contact
+func (field *FormField) AcceptsContact() bool {
+	return field.Type == FormFieldTypePhone || field.Key == FormFieldContacts
+}
+
+// TelegramName First and last name from participant's Telegram profile
+func (participant *ContestParticipant) TelegramName() string {
+	return strings.TrimSpace(participant.FirstName + " " + participant.LastName)
+}
+
+// PatternRegexp Compiled pattern of the form field matching the whole answer, nil without pattern
+func (field *FormField) PatternRegexp() (*regexp.Regexp, error) {
+	if len(field.Pattern) == 0 {
+		return nil, nil
+	}
+	//errors are reported for the pattern as entered by organizers
+	if _, err := regexp.Compile(field.Pattern); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + field.Pattern + ")$")
+}
+
+// Normalize Check the answer to the form field, returns normalized answer or ValidationError
+func (field *FormField) Normalize(text string) (string, error) {
+	value := strings.TrimSpace(text)
+	if field.MaxLength > 0 {
+		runes := []rune(value)
+		if len(runes) > field.MaxLength {
+			value = string(runes[0:field.MaxLength])
+		}
+	}
+
+	if len(value) == 0 {
+		if field.Required {
+			return "", validationError("validation.required")
+		}
+		return "", nil
+	}
+
+	switch field.Type {
+	case FormFieldTypeNumber:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "", validationError("validation.number")
+		}
+	case FormFieldTypeEmail:
+		address, err := mail.ParseAddress(value)
+		if err != nil {
+			return "", validationError("validation.email")
+		}
+		value = address.Address
+	case FormFieldTypePhone:
+		phone, ok := normalizePhone(value)
+		if !ok {
+			return "", validationError("validation.phone")
+		}
+		value = phone
+	case FormFieldTypeFullName:
+		name, ok := normalizeFullName(value)
+		if !ok {
+			return "", validationError("validation.full_name")
+		}
+		value = name
+	case FormFieldTypeChoice:
+		choice, ok := field.choice(value)
+		if !ok {
+			return "", validationError("validation.choice", strings.Join(field.Choices, ", "))
+		}
+		value = choice
+	}
+
+	pattern, err := field.PatternRegexp()
+	if err != nil {
+		//invalid patterns are rejected when the form is saved
+		return "", validationError("validation.pattern")
+	}
+	if pattern != nil && !pattern.MatchString(value) {
+		return "", validationError("validation.pattern")
+	}
+
+	return value, nil
+}
+
+// normalizePhone Phone number in E.164 format. Numbers without country code are accepted
+// only in Russian national format (8 or 7 and 10 digits)
+func normalizePhone(value string) (string, bool) {
+	if !phoneRegexp.MatchString(value) {
+		return "", false
+	}
+	digits := nonDigitRegexp.ReplaceAllString(value, "")
+	if !strings.HasPrefix(value, "+") {
+		if len(digits) != 11 || (digits[0] != '8' && digits[0] != '7') {
+			return "", false
+		}
+		digits = "7" + digits[1:]
+	}
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", false
+	}
+	return "+" + digits, true
+}
+
+// normalizeFullName Name of at least two words, each word in Cyrillic or Latin letters only
+func normalizeFullName(value string) (string, bool) {
+	words := strings.Fields(value)
+	if len(words) < 2 {
+		return "", false
+	}
+	for _, word := range words {
+		if !nameWordRegexp.MatchString(word) {
+			return "", false
+		}
+	}
+	return strings.Join(words, " "), true
+}
+
+// choice Allowed answer matching the value regardless of case
+func (field *FormField) choice(value string) (string, bool) {
+	for _, choice := range field.Choices {
+		if strings.EqualFold(choice, value) {
+			return choice, true
+		}
+	}
+	return "", false
+}
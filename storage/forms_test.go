@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"+7 (912) 345-67-89", "+79123456789", true},
+		{"8 912 345 67 89", "+79123456789", true},
+		{"79123456789", "+79123456789", true},
+		{"+44 20 7946 0958", "+442079460958", true},
+		{"+1-202-555-0123", "+12025550123", true},
+		{"+1234567", "", false},
+		{"+1234567890123456", "", false},
+		{"+0 123 456 789", "", false},
+		{"9123456789", "", false},
+		{"5 912 345 67 89", "", false},
+		{"8 912 345 67 8", "", false},
+		{"8-912-345-67-89 доб. 1", "", false},
+		{"phone", "", false},
+		{"1234", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, ok := normalizePhone(test.value)
+			if got != test.want || ok != test.ok {
+				t.Errorf("normalizePhone(%q) = %q, %t, want %q, %t", test.value, got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestNormalizeFullName(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"Иванов Иван Иванович", "Иванов Иван Иванович", true},
+		{"  Иванов   Иван ", "Иванов Иван", true},
+		{"John Smith", "John Smith", true},
+		{"Римский-Корсаков Николай", "Римский-Корсаков Николай", true},
+		{"O'Brien Conan", "O'Brien Conan", true},
+		{"Д’Артаньян Шарль", "Д’Артаньян Шарль", true},
+		{"Иванов\tИван", "Иванов Иван", true},
+		{"Иванов", "", false},
+		{"Ivanов Иван", "", false},
+		{"Иванов Иван2", "", false},
+		{"Иванов -Иван", "", false},
+		{"Иванов Иван-", "", false},
+		{"Иванов И.", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, ok := normalizeFullName(test.value)
+			if got != test.want || ok != test.ok {
+				t.Errorf("normalizeFullName(%q) = %q, %t, want %q, %t", test.value, got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestFormFieldNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FormField
+		text  string
+		want  string
+		err   string
+	}{
+		{"text is trimmed", FormField{Type: FormFieldTypeText}, "  answer \n", "answer", ""},
+		{"text is cut to max length", FormField{Type: FormFieldTypeText, MaxLength: 5}, "Привет, мир", "Приве", ""},
+		{"required", FormField{Type: FormFieldTypeText, Required: true}, "  ", "", "validation.required"},
+		{"optional empty", FormField{Type: FormFieldTypeNumber}, "", "", ""},
+		{"number", FormField{Type: FormFieldTypeNumber}, "-42", "-42", ""},
+		{"not a number", FormField{Type: FormFieldTypeNumber}, "4.2", "", "validation.number"},
+		{"email", FormField{Type: FormFieldTypeEmail}, "Ivan <ivan@example.com>", "ivan@example.com", ""},
+		{"not an email", FormField{Type: FormFieldTypeEmail}, "ivan@", "", "validation.email"},
+		{"phone", FormField{Type: FormFieldTypePhone}, "8 (912) 345-67-89", "+79123456789", ""},
+		{"not a phone", FormField{Type: FormFieldTypePhone}, "912", "", "validation.phone"},
+		{"full name", FormField{Type: FormFieldTypeFullName}, "Иванов  Иван", "Иванов Иван", ""},
+		{"not a full name", FormField{Type: FormFieldTypeFullName}, "Иван", "", "validation.full_name"},
+		{"choice ignores case", FormField{Type: FormFieldTypeChoice, Choices: []string{"Python", "C++"}}, "python", "Python", ""},
+		{"not a choice", FormField{Type: FormFieldTypeChoice, Choices: []string{"Python", "C++"}}, "Go", "", "validation.choice"},
+		{"pattern matches whole answer", FormField{Type: FormFieldTypeText, Pattern: `[0-9]{1,2}[А-Я]`}, "11А", "11А", ""},
+		{"pattern mismatch", FormField{Type: FormFieldTypeText, Pattern: `[0-9]{1,2}[А-Я]`}, "11А класс", "", "validation.pattern"},
+		{"pattern after type", FormField{Type: FormFieldTypePhone, Pattern: `\+7[0-9]+`}, "+1 202 555 0123", "", "validation.pattern"},
+		{"invalid pattern", FormField{Type: FormFieldTypeText, Pattern: `[`}, "answer", "", "validation.pattern"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.field.Normalize(test.text)
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			} else {
+				var validation *ValidationError
+				if !errors.As(err, &validation) || validation.Message != test.err {
+					t.Fatalf("error = %v, want %s", err, test.err)
+				}
+			}
+			if got != test.want {
+				t.Errorf("Normalize(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormFieldNormalizeChoiceArgs(t *testing.T) {
+	field := FormField{Type: FormFieldTypeChoice, Choices: []string{"Python", "C++"}}
+	_, err := field.Normalize("Go")
+
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("error = %v, want ValidationError", err)
+	}
+	if len(validation.Args) != 1 || validation.Args[0] != "Python, C++" {
+		t.Errorf("args = %v, want allowed answers", validation.Args)
+	}
+}
+
+func TestDefaultFormFields(t *testing.T) {
+	tests := []struct {
+		lang  string
+		title string
+	}{
+		{"ru", "ФИО"},
+		{"en", "Full name"},
+		{"de", "ФИО"},
+	}
+
+	contest := &Contest{}
+	for _, test := range tests {
+		t.Run(test.lang, func(t *testing.T) {
+			fields := DefaultFormFields(test.lang)
+			if fields[0].Title != test.title {
+				t.Errorf("title = %q, want %q", fields[0].Title, test.title)
+			}
+			for i, field := range fields {
+				if field.Key != contest.FormFields()[i].Key {
+					t.Errorf("field %d key = %s, want %s", i, field.Key, contest.FormFields()[i].Key)
+				}
+				for _, text := range []string{field.Title, field.Prompt, field.Example} {
+					if strings.HasPrefix(text, "form.") {
+						t.Errorf("field %s text %q not found in catalog", field.Key, text)
+					}
+				}
+			}
+		})
+	}
+}
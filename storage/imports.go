@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+const (
+	ImportColumnLogin    = "@login"
+	ImportColumnPassword = "@password"
+	ImportColumnRoom     = "@room"
+	ImportColumnSeat     = "@seat"
+)
+
+// GetParticipantImport Find uploaded participants table
+func GetParticipantImport(id uint64) (*ParticipantImport, error) {
+	var participantImport ParticipantImport
+	if err := store.FindOne(&participantImport, bolthold.Where(bolthold.Key).Eq(id)); err != nil {
+		return nil, err
+	}
+	return &participantImport, nil
+}
+
+// SaveParticipantImport Create new or update uploaded participants table
+func SaveParticipantImport(participantImport *ParticipantImport) error {
+	if participantImport.Id != 0 {
+		return store.Update(participantImport.Id, participantImport)
+	} else {
+		return store.Insert(bolthold.NextSequence(), participantImport)
+	}
+}
+
+// DeleteParticipantImport Remove uploaded participants table
+func DeleteParticipantImport(id uint64) error {
+	return store.Delete(id, &ParticipantImport{})
+}
+
+// DeleteExpiredParticipantImports Remove tables uploaded before given time and never confirmed
+func DeleteExpiredParticipantImports(before time.Time) error {
+	return store.DeleteMatching(&ParticipantImport{}, bolthold.Where("CreatedAt").Lt(before))
+}
+
+// ImportContestParticipants Create all participants with generated credentials in one transaction,
+// nothing is created when any of them fails
+func ImportContestParticipants(importId uint64, participants []*ContestParticipant) error {
+	return store.Bolt().Update(func(tx *bolt.Tx) error {
+		contests := make(map[uint64]*Contest)
+
+		for _, participant := range participants {
+			contest, ok := contests[participant.ContestId]
+			if !ok {
+				contest = &Contest{}
+				if err := store.TxGet(tx, participant.ContestId, contest); err != nil {
+					return err
+				}
+				contests[participant.ContestId] = contest
+			}
+
+			if err := txGenerateCredentials(tx, contest, participant); err != nil {
+				return err
+			}
+			if err := store.TxInsert(tx, bolthold.NextSequence(), participant); err != nil {
+				return err
+			}
+		}
+
+		return store.TxDelete(tx, importId, &ParticipantImport{})
+	})
+}
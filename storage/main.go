@@ -3,11 +3,13 @@ package storage
 import (
 	"errors"
 	"github.com/timshannon/bolthold"
-	"math/rand"
+	bolt "go.etcd.io/bbolt"
 	"sort"
-	"strings"
+	"time"
 )
 
+const openTimeout = 3 * time.Second
+
 var (
 	store *bolthold.Store
 )
@@ -15,7 +17,12 @@ var (
 func Open(fileName string) error {
 	var err error
 
-	store, err = bolthold.Open(fileName, 0666, nil)
+	store, err = bolthold.Open(fileName, 0666, &bolthold.Options{
+		Options: &bolt.Options{Timeout: openTimeout},
+	})
+	if err == bolt.ErrTimeout {
+		return errors.New("database is used by another process, stop it or use web backup and export")
+	}
 	if err != nil {
 		return err
 	}
@@ -49,15 +56,6 @@ func GetContest(id uint64) (*Contest, error) {
 	return &contest, nil
 }
 
-// GetContestByName Find contest by its name
-func GetContestByName(name string) (*Contest, error) {
-	var contest Contest
-	if err := store.FindOne(&contest, bolthold.Where("Name").Eq(name)); err != nil {
-		return nil, err
-	}
-	return &contest, nil
-}
-
 // SaveContest Create new or update contest
 func SaveContest(contest *Contest) error {
 	if contest.Id != 0 {
@@ -90,10 +88,21 @@ func GetContestParticipants(contestId uint64) ([]ContestParticipant, error) {
 	return participants, nil
 }
 
-// GetContestParticipantParticipation Participant registrations
+// GetContestParticipantParticipation Participant registrations, including teams the participant is a member of
 func GetContestParticipantParticipation(participantId int64) ([]ContestParticipant, error) {
+	query := bolthold.Where("ParticipantId").Eq(participantId).
+		Or(bolthold.Where("Members").MatchFunc(func(ra *bolthold.RecordAccess) (bool, error) {
+			members, _ := ra.Field().([]TeamMember)
+			for _, member := range members {
+				if member.ChatId == participantId {
+					return true, nil
+				}
+			}
+			return false, nil
+		}))
+
 	var participants []ContestParticipant
-	if err := store.Find(&participants, bolthold.Where("ParticipantId").Eq(participantId)); err != nil {
+	if err := store.Find(&participants, query); err != nil {
 		return nil, err
 	}
 	return participants, nil
@@ -110,39 +119,132 @@ func GetContestParticipant(id uint64) (*ContestParticipant, error) {
 
 // SaveContestParticipant Create new or update contest registration
 func SaveContestParticipant(participant *ContestParticipant) error {
-	if len(participant.Login) == 0 {
-		participant.Login = "p_" + generateRandomString(5)
-	}
-	if len(participant.Password) == 0 {
-		participant.Password = generateRandomString(10)
-	}
+	return store.Bolt().Update(func(tx *bolt.Tx) error {
+		var contest Contest
+		if err := store.TxGet(tx, participant.ContestId, &contest); err != nil {
+			return err
+		}
 
-	if participant.Id != 0 {
-		return store.Update(participant.Id, participant)
-	} else {
-		return store.Insert(bolthold.NextSequence(), participant)
-	}
+		if err := txGenerateCredentials(tx, &contest, participant); err != nil {
+			return err
+		}
+
+		if participant.Id != 0 {
+			return store.TxUpdate(tx, participant.Id, participant)
+		} else {
+			return store.TxInsert(tx, bolthold.NextSequence(), participant)
+		}
+	})
 }
 
-// DeleteContestParticipant Delete contest registration
-func DeleteContestParticipant(id uint64) error {
-	return store.Delete(id, &ContestParticipant{})
+// RegisterContestParticipant Create new contest registration,
+// participant goes to the waitlist when contest capacity is reached
+func RegisterContestParticipant(participant *ContestParticipant) error {
+	return store.Bolt().Update(func(tx *bolt.Tx) error {
+		var contest Contest
+		if err := store.TxGet(tx, participant.ContestId, &contest); err != nil {
+			return err
+		}
+
+		if contest.Capacity > 0 {
+			registered, err := store.TxCount(tx, &ContestParticipant{}, bolthold.
+				Where("ContestId").Eq(contest.Id).
+				And("Waitlisted").Eq(false))
+			if err != nil {
+				return err
+			}
+			participant.Waitlisted = registered >= contest.Capacity
+		}
+
+		if err := txGenerateCredentials(tx, &contest, participant); err != nil {
+			return err
+		}
+
+		return store.TxInsert(tx, bolthold.NextSequence(), participant)
+	})
 }
 
-func generateRandomString(length int) string {
-	vowels := []rune{'e', 'u', 'i', 'o', 'a'}
-	consonants := []rune{'q', 'r', 't', 'p', 's', 'd', 'g', 'h', 'k', 'z', 'x', 'v', 'b', 'n', 'm'}
+// CountRegisteredParticipants Count of contest participants not in the waitlist
+func CountRegisteredParticipants(contestId uint64) (int, error) {
+	return store.Count(&ContestParticipant{}, bolthold.
+		Where("ContestId").Eq(contestId).
+		And("Waitlisted").Eq(false))
+}
+
+// GetWaitlistPosition Position of the participant in the contest waitlist, starting from 1
+func GetWaitlistPosition(participant *ContestParticipant) (int, error) {
+	if !participant.Waitlisted {
+		return 0, nil
+	}
+	ahead, err := store.Count(&ContestParticipant{}, bolthold.
+		Where("ContestId").Eq(participant.ContestId).
+		And("Waitlisted").Eq(true).
+		And(bolthold.Key).Lt(participant.Id))
+	if err != nil {
+		return 0, err
+	}
+	return ahead + 1, nil
+}
 
-	str := strings.Builder{}
+// PromoteWaitlistedParticipants Move participants from the waitlist while contest has free places,
+// returns promoted participants
+func PromoteWaitlistedParticipants(contestId uint64) ([]ContestParticipant, error) {
+	var promoted []ContestParticipant
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		var contest Contest
+		if err := store.TxGet(tx, contestId, &contest); err != nil {
+			return err
+		}
 
-	for i := 0; i < length; i += 2 {
-		str.WriteRune(consonants[rand.Intn(len(consonants))])
-		if i != length-1 {
-			str.WriteRune(vowels[rand.Intn(len(vowels))])
+		var waitlist []ContestParticipant
+		if err := store.TxFind(tx, &waitlist, bolthold.
+			Where("ContestId").Eq(contestId).
+			And("Waitlisted").Eq(true)); err != nil {
+			return err
 		}
+		if len(waitlist) == 0 {
+			return nil
+		}
+		sort.Slice(waitlist, func(i, j int) bool {
+			return waitlist[i].Id < waitlist[j].Id
+		})
+
+		free := len(waitlist)
+		if contest.Capacity > 0 {
+			registered, err := store.TxCount(tx, &ContestParticipant{}, bolthold.
+				Where("ContestId").Eq(contestId).
+				And("Waitlisted").Eq(false))
+			if err != nil {
+				return err
+			}
+			free = min(free, contest.Capacity-registered)
+		}
+
+		for i := 0; i < free; i++ {
+			participant := waitlist[i]
+			participant.Waitlisted = false
+			if err := txGenerateCredentials(tx, &contest, &participant); err != nil {
+				return err
+			}
+			if err := store.TxUpdate(tx, participant.Id, &participant); err != nil {
+				return err
+			}
+			promoted = append(promoted, participant)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return str.String()
+	return promoted, nil
+}
+
+// DeleteContestParticipant Delete contest registration
+func DeleteContestParticipant(id uint64) error {
+	return store.Delete(id, &ContestParticipant{})
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -181,6 +283,25 @@ func DeleteDialogState(participantId int64) error {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+// GetChatSettings Chat preferences, empty settings when chat has none
+func GetChatSettings(chatId int64) (*ChatSettings, error) {
+	settings := ChatSettings{ChatId: chatId}
+	if err := store.Get(chatId, &settings); err != nil && err != bolthold.ErrNotFound {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveChatSettings Save chat preferences
+func SaveChatSettings(settings *ChatSettings) error {
+	if settings.ChatId == 0 {
+		return errors.New("saving chat settings with empty ChatId")
+	}
+	return store.Upsert(settings.ChatId, settings)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 // GetContestNotifications List all contest notifications
 func GetContestNotifications(contestId uint64) ([]ContestNotification, error) {
 	var notifications []ContestNotification
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// openTestStore Open empty database in temporary directory, closed after the test
+func openTestStore(t *testing.T) {
+	t.Helper()
+	if err := Open(filepath.Join(t.TempDir(), "bolt.db")); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := Close(); err != nil {
+			t.Errorf("unable to close storage: %s", err)
+		}
+	})
+}
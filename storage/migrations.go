@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// Migration One step of database schema evolution
+type Migration struct {
+	Version     int
+	Description string
+	// Run Apply migration inside transaction, returns number of changed records
+	Run func(tx *bolt.Tx) (int, error)
+}
+
+// MigrationResult Outcome of applied (or checked in dry run) migration
+type MigrationResult struct {
+	Version     int
+	Description string
+	Changed     int
+}
+
+var (
+	schemaBucket     = []byte("SchemaVersion")
+	schemaVersionKey = []byte("version")
+
+	errDryRun = errors.New("dry run")
+)
+
+// migrations All schema migrations in order of versions.
+// Never change or remove applied migrations, add new ones to the end
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "move free-text contest date into contest note",
+		Run:         migrateContestNotes,
+	},
+	{
+		Version:     2,
+		Description: "move legacy participant fields into form answers",
+		Run:         migrateParticipantAnswers,
+	},
+	{
+		Version:     3,
+		Description: "convert legacy registration dialogs to form field steps",
+		Run:         migrateRegistrationDialogs,
+	},
+	{
+		Version:     4,
+		Description: "store automatic reminder texts as message keys",
+		Run:         migrateReminderMessages,
+	},
+}
+
+// LatestSchemaVersion Schema version supported by this build
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion Current schema version of the opened database
+func SchemaVersion() (int, error) {
+	version := 0
+	err := store.Bolt().View(func(tx *bolt.Tx) error {
+		version = txSchemaVersion(tx)
+		return nil
+	})
+	return version, err
+}
+
+// PendingMigrations Migrations not yet applied to the opened database
+func PendingMigrations() ([]Migration, error) {
+	version, err := SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > LatestSchemaVersion() {
+		return nil, fmt.Errorf("database schema version %d is newer than supported version %d", version, LatestSchemaVersion())
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate Apply pending migrations, each one in its own transaction.
+// Database file is backed up before the first migration.
+// In dry run migrations are applied and rolled back in one transaction
+func Migrate(dryRun bool) ([]MigrationResult, error) {
+	pending, err := PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if dryRun {
+		return migrateDryRun(pending)
+	}
+
+	if _, err := BackupBeforeMigration(); err != nil {
+		return nil, fmt.Errorf("unable to backup database: %s", err)
+	}
+
+	var results []MigrationResult
+
+	for _, migration := range pending {
+		changed := 0
+		err := store.Bolt().Update(func(tx *bolt.Tx) error {
+			var err error
+			if changed, err = migration.Run(tx); err != nil {
+				return err
+			}
+			return txSetSchemaVersion(tx, migration.Version)
+		})
+		if err != nil {
+			return results, fmt.Errorf("migration %d (%s) failed: %s", migration.Version, migration.Description, err)
+		}
+		results = append(results, MigrationResult{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Changed:     changed,
+		})
+	}
+
+	return results, nil
+}
+
+func migrateDryRun(pending []Migration) ([]MigrationResult, error) {
+	var results []MigrationResult
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		for _, migration := range pending {
+			changed, err := migration.Run(tx)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %s", migration.Version, migration.Description, err)
+			}
+			results = append(results, MigrationResult{
+				Version:     migration.Version,
+				Description: migration.Description,
+				Changed:     changed,
+			})
+		}
+		return errDryRun
+	})
+	if err != errDryRun {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// BackupBeforeMigration Copy database file next to it, returns backup file name.
+// Empty database is not backed up
+func BackupBeforeMigration() (string, error) {
+	fileName := ""
+
+	err := store.Bolt().View(func(tx *bolt.Tx) error {
+		empty := true
+		_ = tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			empty = false
+			return nil
+		})
+		if empty {
+			return nil
+		}
+
+		fileName = fmt.Sprintf("%s.v%d-%s.bak", store.Bolt().Path(), txSchemaVersion(tx), time.Now().Format("20060102-150405"))
+		return tx.CopyFile(fileName, 0600)
+	})
+
+	return fileName, err
+}
+
+func txSchemaVersion(tx *bolt.Tx) int {
+	bucket := tx.Bucket(schemaBucket)
+	if bucket == nil {
+		return 0
+	}
+	value := bucket.Get(schemaVersionKey)
+	if len(value) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(value))
+}
+
+func txSetSchemaVersion(tx *bolt.Tx, version int) error {
+	bucket, err := tx.CreateBucketIfNotExists(schemaBucket)
+	if err != nil {
+		return err
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(version))
+	return bucket.Put(schemaVersionKey, value)
+}
+
+// txMigrateRecords Rewrite raw records of the bucket,
+// update returns new record value or nil when record is not changed
+func txMigrateRecords(tx *bolt.Tx, bucketName string, update func(value []byte) ([]byte, error)) (int, error) {
+	bucket := tx.Bucket([]byte(bucketName))
+	if bucket == nil {
+		return 0, nil
+	}
+
+	migrated := make(map[string][]byte)
+
+	err := bucket.ForEach(func(key, value []byte) error {
+		if value == nil {
+			return nil
+		}
+		data, err := update(value)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			migrated[string(key)] = data
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for key, data := range migrated {
+		if err := bucket.Put([]byte(key), data); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(migrated), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// legacyContestWhen Free-text contest date stored before structured contest times
+type legacyContestWhen struct {
+	When string
+	Note string
+}
+
+// migrateContestNotes Move free-text contest date into contest note
+func migrateContestNotes(tx *bolt.Tx) (int, error) {
+	return txMigrateRecords(tx, "Contest", func(value []byte) ([]byte, error) {
+		var legacy legacyContestWhen
+		if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&legacy); err != nil {
+			return nil, err
+		}
+		if len(legacy.When) == 0 || len(legacy.Note) != 0 {
+			return nil, nil
+		}
+
+		var contest Contest
+		if err := bolthold.DefaultDecode(value, &contest); err != nil {
+			return nil, err
+		}
+		contest.Note = legacy.When
+
+		return bolthold.DefaultEncode(&contest)
+	})
+}
+
+// legacyParticipantFields Participant's answers stored before configurable forms
+type legacyParticipantFields struct {
+	Name      string
+	School    string
+	Contacts  string
+	Languages string
+}
+
+// migrateParticipantAnswers Move legacy participant fields into form answers
+func migrateParticipantAnswers(tx *bolt.Tx) (int, error) {
+	return txMigrateRecords(tx, "ContestParticipant", func(value []byte) ([]byte, error) {
+		var legacy legacyParticipantFields
+		if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&legacy); err != nil {
+			return nil, err
+		}
+
+		var participant ContestParticipant
+		if err := bolthold.DefaultDecode(value, &participant); err != nil {
+			return nil, err
+		}
+
+		legacyAnswers := map[string]string{
+			FormFieldName: legacy.Name,
+			"school":      legacy.School,
+			"contacts":    legacy.Contacts,
+			"languages":   legacy.Languages,
+		}
+		changed := len(legacy.School) != 0 || len(legacy.Contacts) != 0 || len(legacy.Languages) != 0
+		for key, answer := range legacyAnswers {
+			if _, ok := participant.Answers[key]; ok || len(answer) == 0 {
+				continue
+			}
+			participant.SetAnswer(key, answer)
+			changed = true
+		}
+		if !changed {
+			return nil, nil
+		}
+
+		return bolthold.DefaultEncode(&participant)
+	})
+}
+
+// legacyRegistrationSteps Registration dialog steps before configurable forms
+// and indexes of corresponding default form fields
+var legacyRegistrationSteps = map[string]int{
+	"name":      0,
+	"school":    1,
+	"contacts":  2,
+	"languages": 3,
+}
+
+// legacyRegistrationValues Registration dialog values before configurable forms
+var legacyRegistrationValues = map[string]string{
+	"Name":      FormFieldName,
+	"School":    "school",
+	"Contacts":  "contacts",
+	"Languages": "languages",
+}
+
+// migrateRegistrationDialogs Continue registrations started before configurable forms
+// from the same question with form field steps
+func migrateRegistrationDialogs(tx *bolt.Tx) (int, error) {
+	var states []DialogState
+	if err := store.TxFind(tx, &states, bolthold.Where("DialogType").Eq("registration")); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+
+	for _, state := range states {
+		fieldIndex, ok := legacyRegistrationSteps[state.DialogStep]
+		if !ok {
+			continue
+		}
+
+		for legacyKey, fieldKey := range legacyRegistrationValues {
+			value, ok := state.Values[legacyKey]
+			if !ok {
+				continue
+			}
+			delete(state.Values, legacyKey)
+			state.Values["Answer."+fieldKey] = value
+		}
+		state.Values["FieldIndex"] = fieldIndex
+		state.DialogStep = "field"
+
+		if err := store.TxUpdate(tx, state.ParticipantId, &state); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+
+	return changed, nil
+}
+
+// legacyReminderMessage Russian text of automatic reminder generated before message catalogs
+// and message key replacing it
+type legacyReminderMessage struct {
+	Text string
+	Key  string
+}
+
+var legacyReminderMessages = map[time.Duration]legacyReminderMessage{
+	24 * time.Hour: {"Напоминаем: контест \"%s\" начнется завтра, %s. Место проведения: %s", "bot.reminders.day_before"},
+	time.Hour:      {"Напоминаем: контест \"%s\" начнется через час, %s. Место проведения: %s", "bot.reminders.hour_before"},
+}
+
+const legacyReminderTimeFormat = "02.01.2006 15:04"
+
+// migrateReminderMessages Replace generated reminder texts with message keys,
+// so reminders are sent in the language of each participant. Edited reminders are kept
+func migrateReminderMessages(tx *bolt.Tx) (int, error) {
+	var notifications []ContestNotification
+	if err := store.TxFind(tx, &notifications, bolthold.Where("ReminderOffset").Ne(time.Duration(0))); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+
+	for _, notification := range notifications {
+		legacy, ok := legacyReminderMessages[notification.ReminderOffset]
+		if !ok {
+			continue
+		}
+		var contest Contest
+		if err := store.TxGet(tx, notification.ContestId, &contest); err != nil {
+			if errors.Is(err, bolthold.ErrNotFound) {
+				continue
+			}
+			return 0, err
+		}
+		generated := fmt.Sprintf(legacy.Text, contest.Name, contest.InLocation(contest.StartsAt).Format(legacyReminderTimeFormat), contest.Where)
+		if notification.Message != generated {
+			continue
+		}
+
+		notification.Message = legacy.Key
+		if err := store.TxUpdate(tx, notification.Id, &notification); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// ReminderTemplates All supported automatic contest reminders
+func ReminderTemplates() []ReminderTemplate {
+	return []ReminderTemplate{
+		{
+			Offset:  24 * time.Hour,
+			Title:   "web.reminders.day_before",
+			Message: "bot.reminders.day_before",
+		},
+		{
+			Offset:  time.Hour,
+			Title:   "web.reminders.hour_before",
+			Message: "bot.reminders.hour_before",
+		},
+	}
+}
+
+// Minutes Reminder offset in minutes, used as form value
+func (template ReminderTemplate) Minutes() int {
+	return int(template.Offset / time.Minute)
+}
+
+// HasReminder Automatic reminder with given offset is enabled for the contest
+func (contest Contest) HasReminder(offset time.Duration) bool {
+	return slices.Contains(contest.Reminders, offset)
+}
+
+// Scheduled Notification waits for its sending time
+func (notification ContestNotification) Scheduled() bool {
+	return !notification.SendAt.IsZero() && notification.SentAt.IsZero()
+}
+
+// IsReminder Notification was created from reminder template
+func (notification ContestNotification) IsReminder() bool {
+	return notification.ReminderOffset != 0
+}
+
+// Reminder Template of automatic reminder with not edited message, nil for other notifications
+func (notification ContestNotification) Reminder() *ReminderTemplate {
+	if !notification.IsReminder() {
+		return nil
+	}
+	for _, template := range ReminderTemplates() {
+		if template.Offset == notification.ReminderOffset && template.Message == notification.Message {
+			return &template
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// GetSentContestNotifications List contest notifications already sent to participants
+func GetSentContestNotifications(contestId uint64) ([]ContestNotification, error) {
+	notifications, err := GetContestNotifications(contestId)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent []ContestNotification
+	for _, notification := range notifications {
+		if !notification.Scheduled() {
+			sent = append(sent, notification)
+		}
+	}
+
+	return sent, nil
+}
+
+// GetDueNotifications List scheduled notifications with sending time before now
+func GetDueNotifications(now time.Time) ([]ContestNotification, error) {
+	var notifications []ContestNotification
+	if err := store.Find(&notifications, nil); err != nil {
+		return nil, err
+	}
+
+	var due []ContestNotification
+	for _, notification := range notifications {
+		if notification.Scheduled() && !notification.SendAt.After(now) {
+			due = append(due, notification)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].SendAt.Before(due[j].SendAt)
+	})
+
+	return due, nil
+}
+
+// SyncContestReminders Recreate not sent automatic reminders of the contest
+// according to contest start time and enabled reminder templates
+func SyncContestReminders(contest *Contest) error {
+	return store.Bolt().Update(func(tx *bolt.Tx) error {
+		var notifications []ContestNotification
+		if err := store.TxFind(tx, &notifications, bolthold.Where("ContestId").Eq(contest.Id)); err != nil {
+			return err
+		}
+
+		sentReminders := make(map[time.Duration]bool)
+		for _, notification := range notifications {
+			if !notification.IsReminder() {
+				continue
+			}
+			if notification.Scheduled() {
+				if err := store.TxDelete(tx, notification.Id, &ContestNotification{}); err != nil {
+					return err
+				}
+			} else {
+				sentReminders[notification.ReminderOffset] = true
+			}
+		}
+
+		if contest.StartsAt.IsZero() {
+			return nil
+		}
+
+		for _, template := range ReminderTemplates() {
+			if !contest.HasReminder(template.Offset) || sentReminders[template.Offset] {
+				continue
+			}
+			sendAt := contest.StartsAt.Add(-template.Offset)
+			if sendAt.Before(time.Now()) {
+				continue
+			}
+			//message is rendered in the language of each participant when sent
+			reminder := &ContestNotification{
+				ContestId:      contest.Id,
+				Message:        template.Message,
+				SendAt:         sendAt,
+				ReminderOffset: template.Offset,
+			}
+			if err := store.TxInsert(tx, bolthold.NextSequence(), reminder); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+	"time"
+)
+
+const (
+	// AnonymizedName Name of participants and team members whose data was erased
+	AnonymizedName = "[anonymized]"
+
+	anonymizedDeliveryError = "chat data erased"
+)
+
+// AnonymizeResult Records changed by erasure of chat's personal data
+type AnonymizeResult struct {
+	// Participants Anonymized registrations and teams the chat was member of
+	Participants      []ContestParticipant
+	DialogStates      int
+	Deliveries        int
+	WebhookDeliveries int
+}
+
+// HasConsent Participant accepted personal data policy during registration
+func (participant *ContestParticipant) HasConsent() bool {
+	return !participant.ConsentAt.IsZero()
+}
+
+// AnonymizeChat Erase personal data of Telegram chat: names and answers of its registrations,
+// its team memberships, dialog state, settings, notification delivery log and webhook payloads
+func AnonymizeChat(chatId int64) (*AnonymizeResult, error) {
+	if chatId == 0 {
+		return nil, errors.New("chat id required")
+	}
+
+	result := &AnonymizeResult{}
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		var participants []ContestParticipant
+		if err := store.TxFind(tx, &participants, nil); err != nil {
+			return err
+		}
+		sort.Slice(participants, func(i, j int) bool {
+			return participants[i].Id < participants[j].Id
+		})
+
+		for _, participant := range participants {
+			if !participant.HasMember(chatId) {
+				continue
+			}
+			anonymizeParticipant(&participant, chatId)
+			if err := store.TxUpdate(tx, participant.Id, &participant); err != nil {
+				return err
+			}
+			result.Participants = append(result.Participants, participant)
+		}
+
+		count, err := store.TxCount(tx, &DialogState{}, bolthold.Where(bolthold.Key).Eq(chatId))
+		if err != nil {
+			return err
+		}
+		if err := store.TxDeleteMatching(tx, &DialogState{}, bolthold.Where(bolthold.Key).Eq(chatId)); err != nil {
+			return err
+		}
+		result.DialogStates = count
+
+		if err := store.TxDeleteMatching(tx, &ChatSettings{}, bolthold.Where(bolthold.Key).Eq(chatId)); err != nil {
+			return err
+		}
+
+		var deliveries []NotificationDelivery
+		if err := store.TxFind(tx, &deliveries, bolthold.Where("ParticipantId").Eq(chatId)); err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			delivery.ParticipantId = 0
+			if delivery.Status == DeliveryStatusPending {
+				delivery.Status = DeliveryStatusFailed
+				delivery.Error = anonymizedDeliveryError
+			}
+			delivery.UpdatedAt = time.Now()
+			if err := store.TxUpdate(tx, delivery.Id, &delivery); err != nil {
+				return err
+			}
+		}
+		result.Deliveries = len(deliveries)
+
+		//payloads are matched by Telegram id, they also keep withdrawn registrations
+		var webhookDeliveries []WebhookDelivery
+		if err := store.TxFind(tx, &webhookDeliveries, nil); err != nil {
+			return err
+		}
+		for _, delivery := range webhookDeliveries {
+			payload, changed := anonymizeWebhookPayload(delivery.Payload, chatId)
+			if !changed {
+				continue
+			}
+			delivery.Payload = payload
+			if err := store.TxUpdate(tx, delivery.Id, &delivery); err != nil {
+				return err
+			}
+			result.WebhookDeliveries++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// anonymizeParticipant Erase chat's data in registration, answers are erased only when chat is the one registered it
+func anonymizeParticipant(participant *ContestParticipant, chatId int64) {
+	if participant.ParticipantId == chatId {
+		participant.ParticipantId = 0
+		participant.Answers = nil
+		participant.SetAnswer(FormFieldName, AnonymizedName)
+		participant.Phone = ""
+		participant.TelegramUsername = ""
+		participant.FirstName = ""
+		participant.LastName = ""
+	}
+	for i := range participant.Members {
+		if participant.Members[i].ChatId == chatId {
+			participant.Members[i].ChatId = 0
+			participant.Members[i].Name = AnonymizedName
+		}
+	}
+}
+
+// anonymizeWebhookPayload Erase chat's data in participant event payload
+func anonymizeWebhookPayload(payload []byte, chatId int64) ([]byte, bool) {
+	var event map[string]interface{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return payload, false
+	}
+	participant, ok := event["participant"].(map[string]interface{})
+	if !ok {
+		return payload, false
+	}
+
+	//JSON numbers are decoded as float64, exact for Telegram ids
+	changed := false
+	if telegramId, _ := participant["telegram_id"].(float64); int64(telegramId) == chatId {
+		participant["telegram_id"] = 0
+		participant["name"] = AnonymizedName
+		participant["answers"] = map[string]string{FormFieldName: AnonymizedName}
+		for _, key := range []string{"phone", "telegram_username", "first_name", "last_name"} {
+			delete(participant, key)
+		}
+		changed = true
+	}
+	members, _ := participant["members"].([]interface{})
+	for _, value := range members {
+		member, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if telegramId, _ := member["telegram_id"].(float64); int64(telegramId) == chatId {
+			member["telegram_id"] = 0
+			member["name"] = AnonymizedName
+			changed = true
+		}
+	}
+	if !changed {
+		return payload, false
+	}
+
+	anonymizedPayload, err := json.Marshal(event)
+	if err != nil {
+		return payload, false
+	}
+	return anonymizedPayload, true
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeChatWithdrawnRegistration(t *testing.T) {
+	openTestStore(t)
+	const chatId = 500
+
+	contest := &Contest{Name: "contest"}
+	if err := SaveContest(contest); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveWebhook(&Webhook{URL: "http://127.0.0.1/hook", Events: WebhookEvents(), Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	participant := &ContestParticipant{ContestId: contest.Id, ParticipantId: chatId, Phone: "+79001234567", FirstName: "Ivan"}
+	participant.SetAnswer(FormFieldName, "Ivan Petrov")
+	if err := RegisterContestParticipant(participant); err != nil {
+		t.Fatal(err)
+	}
+	//team of other chat the chat was member of
+	team := &ContestParticipant{ContestId: contest.Id, ParticipantId: 600, Members: []TeamMember{{ChatId: 600, Name: "Captain"}, {ChatId: chatId, Name: "Ivan Petrov"}}}
+	team.SetAnswer(FormFieldName, "Team")
+	if err := RegisterContestParticipant(team); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := map[string]string{
+		WebhookEventParticipantCreated: `{"event":"participant.created","participant":{"id":1,"telegram_id":500,"name":"Ivan Petrov",` +
+			`"answers":{"name":"Ivan Petrov"},"phone":"+79001234567","telegram_username":"ivan","first_name":"Ivan"}}`,
+		WebhookEventParticipantDeleted: `{"event":"participant.deleted","participant":{"id":1,"telegram_id":500,"name":"Ivan Petrov",` +
+			`"answers":{"name":"Ivan Petrov"},"phone":"+79001234567"}}`,
+		WebhookEventParticipantUpdated: `{"event":"participant.updated","participant":{"id":2,"telegram_id":600,"name":"Team",` +
+			`"members":[{"telegram_id":600,"name":"Captain"},{"telegram_id":500,"name":"Ivan Petrov"}]}}`,
+	}
+	var deliveries []WebhookDelivery
+	for event, payload := range payloads {
+		created, err := CreateWebhookDeliveries(contest.Id, event, []byte(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		deliveries = append(deliveries, created...)
+	}
+	//other chat's data is kept
+	other, err := CreateWebhookDeliveries(contest.Id, WebhookEventParticipantCreated,
+		[]byte(`{"event":"participant.created","participant":{"id":3,"telegram_id":700,"name":"Anna Smirnova","phone":"+79007654321"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteContestParticipant(participant.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := AnonymizeChat(chatId)
+	if err != nil {
+		t.Fatalf("unable to anonymize: %s", err)
+	}
+	if len(result.Participants) != 1 || result.Participants[0].Id != team.Id {
+		t.Errorf("anonymized %d registrations, want the team only", len(result.Participants))
+	}
+	if result.WebhookDeliveries != len(deliveries) {
+		t.Errorf("webhook deliveries = %d, want %d", result.WebhookDeliveries, len(deliveries))
+	}
+
+	for _, delivery := range deliveries {
+		saved, err := GetWebhookDelivery(delivery.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload := string(saved.Payload)
+		for _, data := range []string{"Ivan Petrov", "+79001234567", "ivan", `"telegram_id":500`} {
+			if strings.Contains(payload, data) {
+				t.Errorf("%s payload keeps %q: %s", saved.Event, data, payload)
+			}
+		}
+	}
+
+	saved, err := GetWebhookDelivery(other[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved.Payload), "Anna Smirnova") {
+		t.Errorf("payload of other chat changed: %s", saved.Payload)
+	}
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"errors"
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTeamSize = 3
+	MaxTeamSize     = 10
+
+	inviteCodeLength = 8
+	// inviteCodeAlphabet Invite codes are typed by hand sometimes, no ambiguous characters
+	inviteCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrTeamNotFound       = errors.New("team not found")
+	ErrTeamFull           = errors.New("team is full")
+	ErrAlreadyRegistered  = errors.New("already registered to this contest")
+	ErrCaptainCannotLeave = errors.New("captain can not leave the team")
+)
+
+// MaxMembers Maximal number of members of the contest team
+func (contest Contest) MaxMembers() int {
+	if contest.TeamSize > 0 {
+		return contest.TeamSize
+	}
+	return DefaultTeamSize
+}
+
+// MemberNames Names of team members
+func (participant *ContestParticipant) MemberNames() []string {
+	var names []string
+	for _, member := range participant.Members {
+		names = append(names, member.Name)
+	}
+	return names
+}
+
+// MembersText Names of team members separated with commas
+func (participant *ContestParticipant) MembersText() string {
+	return strings.Join(participant.MemberNames(), ", ")
+}
+
+// HasMember Chat registered the participant or is a member of the team
+func (participant *ContestParticipant) HasMember(chatId int64) bool {
+	if chatId == 0 {
+		return false
+	}
+	if participant.ParticipantId == chatId {
+		return true
+	}
+	for _, member := range participant.Members {
+		if member.ChatId == chatId {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatIds Chats receiving messages about the registration: participant and all team members
+func (participant *ContestParticipant) ChatIds() []int64 {
+	var chatIds []int64
+	if participant.ParticipantId != 0 {
+		chatIds = append(chatIds, participant.ParticipantId)
+	}
+	for _, member := range participant.Members {
+		if member.ChatId != 0 && member.ChatId != participant.ParticipantId {
+			chatIds = append(chatIds, member.ChatId)
+		}
+	}
+	return chatIds
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// GetTeamByInviteCode Find team by invite code, nil when not found
+func GetTeamByInviteCode(code string) (*ContestParticipant, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) == 0 {
+		return nil, nil
+	}
+
+	var team ContestParticipant
+	if err := store.FindOne(&team, bolthold.Where("InviteCode").Eq(code)); err != nil {
+		if err == bolthold.ErrNotFound {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+	return &team, nil
+}
+
+// JoinTeam Add member to the team, member must not be registered to the contest yet
+func JoinTeam(teamId uint64, member TeamMember) (*ContestParticipant, error) {
+	var team ContestParticipant
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := store.TxGet(tx, teamId, &team); err != nil {
+			if err == bolthold.ErrNotFound {
+				return ErrTeamNotFound
+			}
+			return err
+		}
+
+		var contest Contest
+		if err := store.TxGet(tx, team.ContestId, &contest); err != nil {
+			return err
+		}
+		if !contest.TeamMode {
+			return ErrTeamNotFound
+		}
+		if len(team.Members) >= contest.MaxMembers() {
+			return ErrTeamFull
+		}
+
+		registered, err := txChatRegistered(tx, contest.Id, member.ChatId)
+		if err != nil {
+			return err
+		}
+		if registered {
+			return ErrAlreadyRegistered
+		}
+
+		member.JoinedAt = time.Now()
+		team.Members = append(team.Members, member)
+
+		return store.TxUpdate(tx, team.Id, &team)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// LeaveTeam Remove member from the team, captain withdraws the whole team instead
+func LeaveTeam(teamId uint64, chatId int64) (*ContestParticipant, error) {
+	var team ContestParticipant
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := store.TxGet(tx, teamId, &team); err != nil {
+			if err == bolthold.ErrNotFound {
+				return ErrTeamNotFound
+			}
+			return err
+		}
+		if team.ParticipantId == chatId {
+			return ErrCaptainCannotLeave
+		}
+
+		var members []TeamMember
+		for _, member := range team.Members {
+			if member.ChatId != chatId {
+				members = append(members, member)
+			}
+		}
+		if len(members) == len(team.Members) {
+			return ErrTeamNotFound
+		}
+		team.Members = members
+
+		return store.TxUpdate(tx, team.Id, &team)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// txGenerateInviteCode Fill empty invite code of team mode contest participant
+func txGenerateInviteCode(tx *bolt.Tx, contest *Contest, participant *ContestParticipant) error {
+	if !contest.TeamMode || len(participant.InviteCode) != 0 {
+		return nil
+	}
+
+	for attempt := 0; attempt < maxCredentialAttempts; attempt++ {
+		code, err := randomString(inviteCodeAlphabet, inviteCodeLength)
+		if err != nil {
+			return err
+		}
+		count, err := store.TxCount(tx, &ContestParticipant{}, bolthold.Where("InviteCode").Eq(code))
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			participant.InviteCode = code
+			return nil
+		}
+	}
+
+	return errors.New("unable to generate unique invite code")
+}
+
+// txChatRegistered Chat registered to the contest or is a member of one of its teams
+func txChatRegistered(tx *bolt.Tx, contestId uint64, chatId int64) (bool, error) {
+	var participants []ContestParticipant
+	if err := store.TxFind(tx, &participants, bolthold.Where("ContestId").Eq(contestId)); err != nil {
+		return false, err
+	}
+	for _, participant := range participants {
+		if participant.HasMember(chatId) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
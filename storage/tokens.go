@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/timshannon/bolthold"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	apiTokenPrefix = "crb_"
+
+	// apiTokenTouchInterval Minimal interval between last usage time updates
+	apiTokenTouchInterval = time.Minute
+)
+
+// GetUserAPITokens List of user's API tokens, ordered by id
+func GetUserAPITokens(userId uint64) ([]APIToken, error) {
+	var tokens []APIToken
+	if err := store.Find(&tokens, bolthold.Where("UserId").Eq(userId)); err != nil {
+		return nil, err
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Id < tokens[j].Id
+	})
+	return tokens, nil
+}
+
+// CreateAPIToken Create new API token of user, returns the secret shown only once
+func CreateAPIToken(userId uint64, name string) (*APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return nil, "", errors.New("token name required")
+	}
+
+	random, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret := apiTokenPrefix + random
+
+	token := &APIToken{
+		UserId:    userId,
+		Name:      name,
+		Hash:      apiTokenHash(secret),
+		Hint:      secret[len(secret)-4:],
+		CreatedAt: time.Now(),
+	}
+	if err := store.Insert(bolthold.NextSequence(), token); err != nil {
+		return nil, "", err
+	}
+
+	return token, secret, nil
+}
+
+// GetAPITokenBySecret Find API token by its secret and update last usage time, nil when not found
+func GetAPITokenBySecret(secret string) (*APIToken, error) {
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return nil, nil
+	}
+
+	var token APIToken
+	if err := store.FindOne(&token, bolthold.Where("Hash").Eq(apiTokenHash(secret))); err != nil {
+		if err == bolthold.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if now := time.Now(); now.Sub(token.LastUsedAt) > apiTokenTouchInterval {
+		token.LastUsedAt = now
+		if err := store.Update(token.Id, &token); err != nil {
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
+// DeleteUserAPIToken Revoke API token of the user
+func DeleteUserAPIToken(userId, id uint64) error {
+	return store.DeleteMatching(&APIToken{}, bolthold.
+		Where(bolthold.Key).Eq(id).
+		And("UserId").Eq(userId))
+}
+
+func apiTokenHash(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
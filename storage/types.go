@@ -1,13 +1,71 @@
 package storage
 
+import "time"
+
+const (
+	FormFieldTypeText   = "text"
+	FormFieldTypeNumber = "number"
+	FormFieldTypeEmail  = "email"
+	FormFieldTypePhone  = "phone"
+	// FormFieldTypeFullName At least two words in Cyrillic or Latin letters
+	FormFieldTypeFullName = "full_name"
+	// FormFieldTypeChoice One of the answers listed in Choices
+	FormFieldTypeChoice = "choice"
+
+	// FormFieldName Key of the form field holding participant's name
+	FormFieldName = "name"
+	// FormFieldContacts Key of the default form field with free-form contacts
+	FormFieldContacts = "contacts"
+)
+
 type Contest struct {
-	Id          uint64 `boltholdKey:"Id"`
-	Name        string
-	Description string
-	When        string
-	Where       string
-	Closed      bool
-	Hidden      bool
+	Id                   uint64 `boltholdKey:"Id"`
+	Name                 string
+	Description          string
+	Note                 string
+	Where                string
+	Closed               bool
+	Hidden               bool
+	Capacity             int
+	Fields               []FormField
+	StartsAt             time.Time
+	EndsAt               time.Time
+	RegistrationDeadline time.Time
+	Timezone             string
+	Reminders            []time.Duration
+	Credentials          CredentialPolicy
+	LoginSequence        int
+	// TeamMode Captains register teams, teammates join them with invite code
+	TeamMode bool
+	// TeamSize Maximal number of team members including captain
+	TeamSize int
+}
+
+// CredentialPolicy How logins and passwords of contest participants are generated
+type CredentialPolicy struct {
+	LoginPrefix string
+	// Sequential Number logins (team001, team002, ...) instead of random ones
+	Sequential       bool
+	SequenceDigits   int
+	LoginLength      int
+	PasswordLength   int
+	PasswordAlphabet string
+	ExcludeAmbiguous bool
+}
+
+// FormField One question of the contest registration form
+type FormField struct {
+	Key       string
+	Title     string
+	Prompt    string
+	Example   string
+	MaxLength int
+	Required  bool
+	Type      string
+	// Pattern Regular expression the whole answer must match, checked after the type
+	Pattern string
+	// Choices Allowed answers of choice field
+	Choices []string
 }
 
 type ContestParticipant struct {
@@ -15,11 +73,38 @@ type ContestParticipant struct {
 	ParticipantId int64
 	ContestId     uint64
 	Name          string
-	School        string
-	Contacts      string
-	Languages     string
+	Answers       map[string]string
 	Login         string
 	Password      string
+	Waitlisted    bool
+	// Room and Seat Place assigned by organizers for the contest day
+	Room string
+	Seat string
+	// InviteCode Code to join the team, team mode contests only
+	InviteCode string
+	// Members Team members in order of joining, captain is the first one
+	Members []TeamMember
+	// ConsentAt When personal data policy was accepted in the bot, zero for participants added by organizers
+	ConsentAt time.Time
+	// ConsentVersion Version of accepted personal data policy
+	ConsentVersion string
+	// Phone Phone number of Telegram contact shared in the bot, E.164 format
+	Phone string
+	// TelegramUsername, FirstName and LastName Telegram profile at registration
+	TelegramUsername string
+	FirstName        string
+	LastName         string
+}
+
+// TeamMember Member of the team registered to team mode contest
+type TeamMember struct {
+	// ChatId Zero for members added by organizers
+	ChatId   int64
+	Name     string
+	JoinedAt time.Time
+	// ConsentAt and ConsentVersion Personal data policy accepted when joining the team
+	ConsentAt      time.Time
+	ConsentVersion string
 }
 
 type DialogState struct {
@@ -31,8 +116,129 @@ type DialogState struct {
 
 type DialogValues map[string]interface{}
 
+// ChatSettings Preferences of participant's chat
+type ChatSettings struct {
+	ChatId int64 `boltholdKey:"ChatId"`
+	// Language Language chosen with /language, empty to use Telegram client language
+	Language string
+	// ClientLanguage Language code of participant's Telegram client
+	ClientLanguage string
+}
+
 type ContestNotification struct {
+	Id             uint64 `boltholdKey:"Id"`
+	ContestId      uint64
+	Message        string
+	SendAt         time.Time
+	SentAt         time.Time
+	ReminderOffset time.Duration
+}
+
+// ReminderTemplate Automatic contest reminder sent before contest start
+type ReminderTemplate struct {
+	Offset time.Duration
+	// Title Message key of reminder title in admin panel
+	Title string
+	// Message Message key of reminder text, arguments are contest name, start time and place
+	Message string
+}
+
+const (
+	RoleOrganizer = "organizer"
+	RoleVolunteer = "volunteer"
+)
+
+type User struct {
+	Id           uint64 `boltholdKey:"Id"`
+	Login        string
+	PasswordHash []byte
+	Role         string
+}
+
+type Session struct {
+	Id        string `boltholdKey:"Id"`
+	UserId    uint64
+	ExpiresAt time.Time
+	CSRFToken string
+}
+
+// APIToken Token of admin user for JSON API, only hash of the secret is stored
+type APIToken struct {
+	Id         uint64 `boltholdKey:"Id"`
+	UserId     uint64
+	Name       string
+	Hash       string
+	Hint       string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed"
+	DeliveryStatusBlocked = "blocked"
+)
+
+type NotificationDelivery struct {
+	Id                   uint64 `boltholdKey:"Id"`
+	NotificationId       uint64
+	ContestId            uint64
+	ContestParticipantId uint64
+	ParticipantId        int64
+	Status               string
+	Attempts             int
+	Error                string
+	UpdatedAt            time.Time
+}
+
+const (
+	WebhookEventParticipantCreated = "participant.created"
+	WebhookEventParticipantUpdated = "participant.updated"
+	WebhookEventParticipantDeleted = "participant.deleted"
+	WebhookEventContestOpened      = "contest.opened"
+	WebhookEventContestClosed      = "contest.closed"
+	WebhookEventNotificationSent   = "notification.sent"
+	// WebhookEventPing Test event sent from the admin panel
+	WebhookEventPing = "ping"
+)
+
+// Webhook Outgoing HTTP callback on registration events
+type Webhook struct {
+	Id uint64 `boltholdKey:"Id"`
+	// ContestId Zero for events of all contests
+	ContestId uint64
+	URL       string
+	Secret    string
+	Events    []string
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+// WebhookDelivery Queued or finished call of the webhook with one event
+type WebhookDelivery struct {
+	Id            uint64 `boltholdKey:"Id"`
+	WebhookId     uint64
+	ContestId     uint64
+	Event         string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	ResponseCode  int
+	Error         string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// ParticipantImport Uploaded participants table waiting for column mapping and confirmation
+type ParticipantImport struct {
 	Id        uint64 `boltholdKey:"Id"`
 	ContestId uint64
-	Message   string
+	FileName  string
+	Rows      [][]string
+	HasHeader bool
+	// Columns Form field key (or login, password) of each table column, empty to skip column
+	Columns   []string
+	CreatedAt time.Time
 }
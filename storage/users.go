@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/timshannon/bolthold"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Roles All supported admin user roles
+func Roles() []string {
+	return []string{RoleOrganizer, RoleVolunteer}
+}
+
+// IsOrganizer User has full access to the admin panel
+func (user *User) IsOrganizer() bool {
+	return user.Role == RoleOrganizer
+}
+
+// SetPassword Replace user's password hash
+func (user *User) SetPassword(password string) error {
+	if len(password) == 0 {
+		return errors.New("password required")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	return nil
+}
+
+// CheckPassword Compare password with user's password hash
+func (user *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)) == nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// GetUsers List of all admin users, ordered by id
+func GetUsers() ([]User, error) {
+	var users []User
+	if err := store.Find(&users, nil); err != nil {
+		return nil, err
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+	return users, nil
+}
+
+// GetUser One admin user by id
+func GetUser(id uint64) (*User, error) {
+	var user User
+	if err := store.FindOne(&user, bolthold.Where(bolthold.Key).Eq(id)); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByLogin Find admin user by login, nil when not found
+func GetUserByLogin(login string) (*User, error) {
+	var user User
+	if err := store.FindOne(&user, bolthold.Where("Login").Eq(login)); err != nil {
+		if err == bolthold.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SaveUser Create new or update admin user
+func SaveUser(user *User) error {
+	if len(user.Login) == 0 {
+		return errors.New("user login required")
+	}
+	if !slices.Contains(Roles(), user.Role) {
+		return errors.New("unknown user role: " + user.Role)
+	}
+	if len(user.PasswordHash) == 0 {
+		return errors.New("user password required")
+	}
+
+	existing, err := GetUserByLogin(user.Login)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Id != user.Id {
+		return errors.New("user with login " + user.Login + " already exists")
+	}
+
+	if user.Id != 0 {
+		return store.Update(user.Id, user)
+	} else {
+		return store.Insert(bolthold.NextSequence(), user)
+	}
+}
+
+// DeleteUser Delete admin user, all user's sessions and API tokens
+func DeleteUser(id uint64) error {
+	if err := store.DeleteMatching(&Session{}, bolthold.Where("UserId").Eq(id)); err != nil {
+		return err
+	}
+	if err := store.DeleteMatching(&APIToken{}, bolthold.Where("UserId").Eq(id)); err != nil {
+		return err
+	}
+	return store.Delete(id, &User{})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// CreateSession Start new admin user session
+func CreateSession(userId uint64, lifetime time.Duration) (*Session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		Id:        id,
+		UserId:    userId,
+		ExpiresAt: time.Now().Add(lifetime),
+		CSRFToken: csrfToken,
+	}
+	if err := store.Insert(session.Id, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSession Find not expired session, nil when not found
+func GetSession(id string) (*Session, error) {
+	var session Session
+	if err := store.FindOne(&session, bolthold.Where(bolthold.Key).Eq(id)); err != nil {
+		if err == bolthold.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, DeleteSession(id)
+	}
+
+	//sessions started before CSRF protection
+	if len(session.CSRFToken) == 0 {
+		csrfToken, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		session.CSRFToken = csrfToken
+		if err := store.Update(session.Id, &session); err != nil {
+			return nil, err
+		}
+	}
+
+	return &session, nil
+}
+
+// DeleteSession Finish admin user session
+func DeleteSession(id string) error {
+	if err := store.Delete(id, &Session{}); err != nil && err != bolthold.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpiredSessions Remove all expired sessions
+func DeleteExpiredSessions() error {
+	return store.DeleteMatching(&Session{}, bolthold.Where("ExpiresAt").Lt(time.Now()))
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/timshannon/bolthold"
+	bolt "go.etcd.io/bbolt"
+	"net/url"
+	"slices"
+	"sort"
+	"time"
+)
+
+// WebhookEvents All events webhooks can be subscribed to
+func WebhookEvents() []string {
+	return []string{
+		WebhookEventParticipantCreated,
+		WebhookEventParticipantUpdated,
+		WebhookEventParticipantDeleted,
+		WebhookEventContestOpened,
+		WebhookEventContestClosed,
+		WebhookEventNotificationSent,
+	}
+}
+
+// Subscribed Webhook receives given event of given contest
+func (webhook Webhook) Subscribed(contestId uint64, event string) bool {
+	if !webhook.Enabled {
+		return false
+	}
+	if webhook.ContestId != 0 && webhook.ContestId != contestId {
+		return false
+	}
+	return slices.Contains(webhook.Events, event)
+}
+
+// Validate Check webhook URL and events
+func (webhook *Webhook) Validate() error {
+	address, err := url.Parse(webhook.URL)
+	if err != nil || (address.Scheme != "http" && address.Scheme != "https") || len(address.Host) == 0 {
+		return errors.New("webhook URL must be absolute http or https URL")
+	}
+	if len(webhook.Secret) == 0 {
+		return errors.New("webhook secret required")
+	}
+	if len(webhook.Events) == 0 {
+		return errors.New("at least one webhook event required")
+	}
+	for _, event := range webhook.Events {
+		if !slices.Contains(WebhookEvents(), event) {
+			return fmt.Errorf("unknown webhook event: %s", event)
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// GetWebhooks List of all webhooks, ordered by id
+func GetWebhooks() ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := store.Find(&webhooks, nil); err != nil {
+		return nil, err
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].Id < webhooks[j].Id
+	})
+	return webhooks, nil
+}
+
+// GetWebhook Find webhook by id
+func GetWebhook(id uint64) (*Webhook, error) {
+	var webhook Webhook
+	if err := store.FindOne(&webhook, bolthold.Where(bolthold.Key).Eq(id)); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// SaveWebhook Create new or update webhook, empty secret of new webhook is generated
+func SaveWebhook(webhook *Webhook) error {
+	if webhook.Id == 0 && len(webhook.Secret) == 0 {
+		secret, err := randomToken()
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	}
+	if err := webhook.Validate(); err != nil {
+		return err
+	}
+	if webhook.Id != 0 {
+		return store.Update(webhook.Id, webhook)
+	} else {
+		webhook.CreatedAt = time.Now()
+		return store.Insert(bolthold.NextSequence(), webhook)
+	}
+}
+
+// DeleteWebhook Remove webhook with all its deliveries
+func DeleteWebhook(id uint64) error {
+	return store.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := store.TxDeleteMatching(tx, &WebhookDelivery{}, bolthold.Where("WebhookId").Eq(id)); err != nil {
+			return err
+		}
+		return store.TxDelete(tx, id, &Webhook{})
+	})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// CreateWebhookDeliveries Queue event payload to all webhooks subscribed to the event of the contest
+func CreateWebhookDeliveries(contestId uint64, event string, payload []byte) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		var webhooks []Webhook
+		if err := store.TxFind(tx, &webhooks, bolthold.Where("Enabled").Eq(true)); err != nil {
+			return err
+		}
+		sort.Slice(webhooks, func(i, j int) bool {
+			return webhooks[i].Id < webhooks[j].Id
+		})
+
+		for _, webhook := range webhooks {
+			if !webhook.Subscribed(contestId, event) {
+				continue
+			}
+			delivery, err := txCreateWebhookDelivery(tx, webhook.Id, contestId, event, payload)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, *delivery)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// CreateWebhookDelivery Queue event payload to one webhook
+func CreateWebhookDelivery(webhookId uint64, event string, payload []byte) (*WebhookDelivery, error) {
+	var delivery *WebhookDelivery
+
+	err := store.Bolt().Update(func(tx *bolt.Tx) error {
+		var err error
+		delivery, err = txCreateWebhookDelivery(tx, webhookId, 0, event, payload)
+		return err
+	})
+
+	return delivery, err
+}
+
+func txCreateWebhookDelivery(tx *bolt.Tx, webhookId, contestId uint64, event string, payload []byte) (*WebhookDelivery, error) {
+	now := time.Now()
+	delivery := &WebhookDelivery{
+		WebhookId:     webhookId,
+		ContestId:     contestId,
+		Event:         event,
+		Payload:       payload,
+		Status:        DeliveryStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := store.TxInsert(tx, bolthold.NextSequence(), delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// GetWebhookDelivery Find webhook delivery by id
+func GetWebhookDelivery(id uint64) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := store.FindOne(&delivery, bolthold.Where(bolthold.Key).Eq(id)); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetDueWebhookDeliveries Pending deliveries with next attempt time passed, ordered by id
+func GetDueWebhookDeliveries(now time.Time) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	query := bolthold.
+		Where("Status").Eq(DeliveryStatusPending).
+		And("NextAttemptAt").Le(now)
+	if err := store.Find(&deliveries, query); err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Id < deliveries[j].Id
+	})
+	return deliveries, nil
+}
+
+// GetWebhookDeliveries Latest deliveries of the webhook, newest first,
+// all webhooks when webhookId is zero, all deliveries when limit is zero
+func GetWebhookDeliveries(webhookId uint64, limit int) ([]WebhookDelivery, error) {
+	var query *bolthold.Query
+	if webhookId != 0 {
+		query = bolthold.Where("WebhookId").Eq(webhookId)
+	}
+
+	var deliveries []WebhookDelivery
+	if err := store.Find(&deliveries, query); err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Id > deliveries[j].Id
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// SaveWebhookDelivery Update webhook delivery
+func SaveWebhookDelivery(delivery *WebhookDelivery) error {
+	delivery.UpdatedAt = time.Now()
+	return store.Update(delivery.Id, delivery)
+}
+
+// DeleteFinishedWebhookDeliveries Remove sent and failed deliveries updated before given time
+func DeleteFinishedWebhookDeliveries(before time.Time) error {
+	return store.DeleteMatching(&WebhookDelivery{}, bolthold.
+		Where("Status").Ne(DeliveryStatusPending).
+		And("UpdatedAt").Lt(before))
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"contest-registration-bot/storage"
+	_ "embed"
+	"errors"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/timshannon/bolthold"
+	"net/http"
+	"strings"
+)
+
+const apiPrefix = "/api/v1"
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+// apiErrorResponse Body of all API error responses
+type apiErrorResponse struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// registerAPI Add JSON API routes, API uses tokens instead of sessions and does not need CSRF tokens
+func registerAPI(e *echo.Echo) {
+	e.GET(apiPrefix+"/openapi.yaml", openAPIGet)
+
+	api := e.Group(apiPrefix, apiAuthenticate)
+	api.GET("/contests", apiContestsList)
+	api.GET("/contests/:id", apiContestGet)
+	api.GET("/contests/:id/participants", apiParticipantsList)
+	api.GET("/contests/:id/participants/:participant_id", apiParticipantGet)
+
+	organizer := api.Group("", requireOrganizer)
+	organizer.POST("/contests", apiContestCreate)
+	organizer.PUT("/contests/:id", apiContestUpdate)
+	organizer.DELETE("/contests/:id", apiContestDelete)
+	organizer.POST("/contests/:id/open", apiContestOpen)
+	organizer.POST("/contests/:id/close", apiContestClose)
+	organizer.POST("/contests/:id/hide", apiContestHide)
+	organizer.POST("/contests/:id/show", apiContestShow)
+
+	organizer.GET("/contests/:id/participants/export", participantsExport)
+	organizer.POST("/contests/:id/participants", apiParticipantCreate)
+	organizer.PUT("/contests/:id/participants/:participant_id", apiParticipantUpdate)
+	organizer.DELETE("/contests/:id/participants/:participant_id", apiParticipantDelete)
+
+	organizer.GET("/contests/:id/notifications", apiNotificationsList)
+	organizer.GET("/contests/:id/notifications/:notification_id", apiNotificationGet)
+	organizer.POST("/contests/:id/notifications", apiNotificationCreate)
+	organizer.PUT("/contests/:id/notifications/:notification_id", apiNotificationUpdate)
+	organizer.DELETE("/contests/:id/notifications/:notification_id", apiNotificationDelete)
+}
+
+// openAPIGet OpenAPI description of the JSON API
+func openAPIGet(c echo.Context) error {
+	return c.Blob(http.StatusOK, "application/yaml", openAPIDocument)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// apiAuthenticate Middleware requiring API token of admin user in Authorization header
+func apiAuthenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		secret, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return echo.NewHTTPError(http.StatusUnauthorized, "API token required")
+		}
+
+		token, err := storage.GetAPITokenBySecret(strings.TrimSpace(secret))
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			log.Warnf("invalid API token: url=%s, ip=%s", c.Request().URL, c.RealIP())
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer error=\"invalid_token\"")
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid API token")
+		}
+
+		user, err := storage.GetUser(token.UserId)
+		if err != nil {
+			log.Warnf("API token %d of unknown user %d", token.Id, token.UserId)
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid API token")
+		}
+
+		c.Set(contextUserKey, user)
+
+		return next(c)
+	}
+}
+
+// isAPIRequest Request to the JSON API
+func isAPIRequest(c echo.Context) bool {
+	return strings.HasPrefix(c.Request().URL.Path, apiPrefix+"/")
+}
+
+// apiErrorHandler Write error as JSON, errors of missing records are 404,
+// other errors not created with apiBadRequest or echo.NewHTTPError are 500
+func apiErrorHandler(e error, c echo.Context) {
+	status := http.StatusInternalServerError
+	message := e.Error()
+
+	var httpError *echo.HTTPError
+	switch {
+	case errors.As(e, &httpError):
+		status = httpError.Code
+		if text, ok := httpError.Message.(string); ok {
+			message = text
+		} else {
+			message = http.StatusText(status)
+		}
+	case errors.Is(e, bolthold.ErrNotFound):
+		status = http.StatusNotFound
+		message = "not found"
+	}
+
+	if c.Response().Committed {
+		return
+	}
+	if err := c.JSON(status, apiErrorResponse{Error: apiErrorBody{Status: status, Message: message}}); err != nil {
+		log.Errorf("API error response error: %s", err)
+	}
+}
+
+// apiBadRequest Error of invalid request data
+func apiBadRequest(err error) error {
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,726 @@
+package web
+
+import (
+	"contest-registration-bot/bot"
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type apiContest struct {
+	Id                   uint64               `json:"id"`
+	Name                 string               `json:"name"`
+	Description          string               `json:"description"`
+	Note                 string               `json:"note"`
+	Where                string               `json:"where"`
+	Closed               bool                 `json:"closed"`
+	Hidden               bool                 `json:"hidden"`
+	RegistrationOpen     bool                 `json:"registration_open"`
+	Capacity             int                  `json:"capacity"`
+	TeamMode             bool                 `json:"team_mode"`
+	TeamSize             int                  `json:"team_size"`
+	StartsAt             *time.Time           `json:"starts_at"`
+	EndsAt               *time.Time           `json:"ends_at"`
+	RegistrationDeadline *time.Time           `json:"registration_deadline"`
+	Timezone             string               `json:"timezone"`
+	Reminders            []int                `json:"reminders"`
+	Fields               []apiFormField       `json:"fields"`
+	Credentials          *apiCredentialPolicy `json:"credentials"`
+}
+
+type apiFormField struct {
+	Key       string   `json:"key"`
+	Title     string   `json:"title"`
+	Prompt    string   `json:"prompt"`
+	Example   string   `json:"example"`
+	MaxLength int      `json:"max_length"`
+	Required  bool     `json:"required"`
+	Type      string   `json:"type"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Choices   []string `json:"choices,omitempty"`
+}
+
+type apiCredentialPolicy struct {
+	LoginPrefix      string `json:"login_prefix"`
+	Sequential       bool   `json:"sequential"`
+	SequenceDigits   int    `json:"sequence_digits"`
+	LoginLength      int    `json:"login_length"`
+	PasswordLength   int    `json:"password_length"`
+	PasswordAlphabet string `json:"password_alphabet"`
+	ExcludeAmbiguous bool   `json:"exclude_ambiguous"`
+}
+
+type apiParticipant struct {
+	Id         uint64            `json:"id"`
+	ContestId  uint64            `json:"contest_id"`
+	TelegramId int64             `json:"telegram_id"`
+	Name       string            `json:"name"`
+	Answers    map[string]string `json:"answers"`
+	Login      string            `json:"login"`
+	Password   string            `json:"password"`
+	Room       string            `json:"room"`
+	Seat       string            `json:"seat"`
+	Waitlisted bool              `json:"waitlisted"`
+	InviteCode string            `json:"invite_code,omitempty"`
+	Members    []apiTeamMember   `json:"members,omitempty"`
+	ConsentAt  *time.Time        `json:"consent_at"`
+	// ConsentVersion Version of personal data policy accepted in the bot
+	ConsentVersion string `json:"consent_version,omitempty"`
+	// Phone and Telegram profile collected in the bot
+	Phone            string `json:"phone,omitempty"`
+	TelegramUsername string `json:"telegram_username,omitempty"`
+	FirstName        string `json:"first_name,omitempty"`
+	LastName         string `json:"last_name,omitempty"`
+}
+
+type apiTeamMember struct {
+	TelegramId int64  `json:"telegram_id"`
+	Name       string `json:"name"`
+}
+
+type apiNotification struct {
+	Id         uint64                 `json:"id"`
+	ContestId  uint64                 `json:"contest_id"`
+	Message    string                 `json:"message"`
+	SendAt     *time.Time             `json:"send_at"`
+	SentAt     *time.Time             `json:"sent_at"`
+	Reminder   bool                   `json:"reminder"`
+	Deliveries apiNotificationCounter `json:"deliveries"`
+}
+
+type apiNotificationCounter struct {
+	Pending int `json:"pending"`
+	Sent    int `json:"sent"`
+	Failed  int `json:"failed"`
+	Blocked int `json:"blocked"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+//contests
+
+// apiContestsList List all contests
+func apiContestsList(c echo.Context) error {
+	contests, err := storage.GetContests()
+	if err != nil {
+		return err
+	}
+
+	result := make([]apiContest, 0, len(contests))
+	for _, contest := range contests {
+		result = append(result, newAPIContest(&contest))
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// apiContestGet One contest
+func apiContestGet(c echo.Context) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, newAPIContest(contest))
+}
+
+// apiContestCreate Create new contest
+func apiContestCreate(c echo.Context) error {
+	var contestData apiContest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &contestData); err != nil {
+		return err
+	}
+
+	contest := &storage.Contest{}
+	if err := contestData.apply(requestLanguage(c), contest); err != nil {
+		return apiBadRequest(err)
+	}
+	if err := saveContest(contest); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, newAPIContest(contest))
+}
+
+// apiContestUpdate Update existing contest, omitted fields and credentials are not changed
+func apiContestUpdate(c echo.Context) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+
+	//request is decoded over current values, so omitted fields keep them
+	contestData := newAPIContest(contest)
+	contestData.TeamSize = contest.TeamSize
+	contestData.Fields = nil
+	contestData.Credentials = nil
+	if err := (&echo.DefaultBinder{}).BindBody(c, &contestData); err != nil {
+		return err
+	}
+
+	if err := contestData.apply(requestLanguage(c), contest); err != nil {
+		return apiBadRequest(err)
+	}
+	if err := saveContest(contest); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newAPIContest(contest))
+}
+
+// apiContestDelete Delete contest with all participants and notifications
+func apiContestDelete(c echo.Context) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+	if err := storage.DeleteContest(contest.Id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// apiContestOpen Open contest for registration
+func apiContestOpen(c echo.Context) error {
+	return apiContestUpdateFlag(c, func(contest *storage.Contest) error {
+		if !contest.RegistrationDeadline.IsZero() && !time.Now().Before(contest.RegistrationDeadline) {
+			return apiBadRequest(errors.New("registration deadline passed, change it to open registration"))
+		}
+		contest.Closed = false
+		return nil
+	})
+}
+
+// apiContestClose Close contest for registration
+func apiContestClose(c echo.Context) error {
+	return apiContestUpdateFlag(c, func(contest *storage.Contest) error {
+		contest.Closed = true
+		return nil
+	})
+}
+
+// apiContestHide Hide contest from participants
+func apiContestHide(c echo.Context) error {
+	return apiContestUpdateFlag(c, func(contest *storage.Contest) error {
+		contest.Hidden = true
+		return nil
+	})
+}
+
+// apiContestShow Show contest for participants
+func apiContestShow(c echo.Context) error {
+	return apiContestUpdateFlag(c, func(contest *storage.Contest) error {
+		contest.Hidden = false
+		return nil
+	})
+}
+
+func apiContestUpdateFlag(c echo.Context, update func(contest *storage.Contest) error) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+	wasClosed := contest.Closed
+	if err := update(contest); err != nil {
+		return err
+	}
+	if err := storage.SaveContest(contest); err != nil {
+		return err
+	}
+	if contest.Closed != wasClosed {
+		contestClosedEvent(contest)
+	}
+	return c.JSON(http.StatusOK, newAPIContest(contest))
+}
+
+func newAPIContest(contest *storage.Contest) apiContest {
+	result := apiContest{
+		Id:                   contest.Id,
+		Name:                 contest.Name,
+		Description:          contest.Description,
+		Note:                 contest.Note,
+		Where:                contest.Where,
+		Closed:               contest.Closed,
+		Hidden:               contest.Hidden,
+		RegistrationOpen:     contest.RegistrationOpen(time.Now()),
+		Capacity:             contest.Capacity,
+		TeamMode:             contest.TeamMode,
+		TeamSize:             contest.MaxMembers(),
+		StartsAt:             apiTime(contest.StartsAt),
+		EndsAt:               apiTime(contest.EndsAt),
+		RegistrationDeadline: apiTime(contest.RegistrationDeadline),
+		Timezone:             contest.Timezone,
+		Reminders:            []int{},
+	}
+	for _, reminder := range contest.Reminders {
+		result.Reminders = append(result.Reminders, int(reminder/time.Minute))
+	}
+	for _, field := range contest.FormFields() {
+		result.Fields = append(result.Fields, apiFormField{
+			Key:       field.Key,
+			Title:     field.Title,
+			Prompt:    field.Prompt,
+			Example:   field.Example,
+			MaxLength: field.MaxLength,
+			Required:  field.Required,
+			Type:      field.Type,
+			Pattern:   field.Pattern,
+			Choices:   field.Choices,
+		})
+	}
+	policy := contest.CredentialPolicy()
+	result.Credentials = &apiCredentialPolicy{
+		LoginPrefix:      policy.LoginPrefix,
+		Sequential:       policy.Sequential,
+		SequenceDigits:   policy.SequenceDigits,
+		LoginLength:      policy.LoginLength,
+		PasswordLength:   policy.PasswordLength,
+		PasswordAlphabet: policy.PasswordAlphabet,
+		ExcludeAmbiguous: policy.ExcludeAmbiguous,
+	}
+	return result
+}
+
+// apply Copy editable values to the contest and validate it, default form is created in given language
+func (contestData *apiContest) apply(lang string, contest *storage.Contest) error {
+	contest.Name = strings.TrimSpace(contestData.Name)
+	contest.Description = contestData.Description
+	contest.Note = contestData.Note
+	contest.Where = contestData.Where
+	contest.Capacity = contestData.Capacity
+	contest.TeamMode = contestData.TeamMode
+	contest.TeamSize = contestData.TeamSize
+	contest.StartsAt = storageTime(contestData.StartsAt)
+	contest.EndsAt = storageTime(contestData.EndsAt)
+	contest.RegistrationDeadline = storageTime(contestData.RegistrationDeadline)
+	contest.Timezone = contestData.Timezone
+
+	contest.Reminders = nil
+	for _, minutes := range contestData.Reminders {
+		contest.Reminders = append(contest.Reminders, time.Duration(minutes)*time.Minute)
+	}
+
+	if contestData.Fields != nil {
+		contest.Fields = nil
+		for _, field := range contestData.Fields {
+			formField := storage.FormField{
+				Key:       field.Key,
+				Title:     field.Title,
+				Prompt:    field.Prompt,
+				Example:   field.Example,
+				MaxLength: field.MaxLength,
+				Required:  field.Required,
+				Type:      field.Type,
+				Pattern:   field.Pattern,
+				Choices:   field.Choices,
+			}
+			normalizeFormField(&formField)
+			contest.Fields = append(contest.Fields, formField)
+		}
+	} else if len(contest.Fields) == 0 {
+		contest.Fields = storage.DefaultFormFields(lang)
+	}
+
+	if contestData.Credentials != nil {
+		contest.Credentials = storage.CredentialPolicy{
+			LoginPrefix:      strings.TrimSpace(contestData.Credentials.LoginPrefix),
+			Sequential:       contestData.Credentials.Sequential,
+			SequenceDigits:   contestData.Credentials.SequenceDigits,
+			LoginLength:      contestData.Credentials.LoginLength,
+			PasswordLength:   contestData.Credentials.PasswordLength,
+			PasswordAlphabet: contestData.Credentials.PasswordAlphabet,
+			ExcludeAmbiguous: contestData.Credentials.ExcludeAmbiguous,
+		}
+	} else {
+		contest.Credentials = contest.CredentialPolicy()
+	}
+
+	return validateContest(contest)
+}
+
+func apiContestParam(c echo.Context) (*storage.Contest, error) {
+	var id idRequest
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, &id); err != nil {
+		return nil, err
+	}
+	return storage.GetContest(id.Id)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+//contest participants
+
+// apiParticipantsList List contest participants, registered ones first
+func apiParticipantsList(c echo.Context) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+
+	participants, err := storage.GetContestParticipants(contest.Id)
+	if err != nil {
+		return err
+	}
+
+	result := make([]apiParticipant, 0, len(participants))
+	for _, waitlisted := range []bool{false, true} {
+		for _, participant := range participants {
+			if participant.Waitlisted == waitlisted {
+				result = append(result, newAPIParticipant(&participant, apiCredentialsAllowed(c)))
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// apiParticipantGet One contest participant
+func apiParticipantGet(c echo.Context) error {
+	_, participant, err := apiParticipantParam(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, newAPIParticipant(participant, apiCredentialsAllowed(c)))
+}
+
+// apiParticipantCreate Register participant bypassing contest capacity,
+// empty login and password are generated
+func apiParticipantCreate(c echo.Context) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+
+	var participantData apiParticipant
+	if err := (&echo.DefaultBinder{}).BindBody(c, &participantData); err != nil {
+		return err
+	}
+
+	participant := &storage.ContestParticipant{ContestId: contest.Id}
+	if err := participantData.apply(requestLanguage(c), contest, participant); err != nil {
+		return err
+	}
+	webhooks.ParticipantCreated(participant)
+
+	return c.JSON(http.StatusCreated, newAPIParticipant(participant, apiCredentialsAllowed(c)))
+}
+
+// apiParticipantUpdate Update participant, omitted answers and fields are not changed
+func apiParticipantUpdate(c echo.Context) error {
+	contest, participant, err := apiParticipantParam(c)
+	if err != nil {
+		return err
+	}
+
+	//request is decoded over current values, so omitted fields keep them
+	participantData := newAPIParticipant(participant, true)
+	participantData.Answers = nil
+	if err := (&echo.DefaultBinder{}).BindBody(c, &participantData); err != nil {
+		return err
+	}
+
+	if err := participantData.apply(requestLanguage(c), contest, participant); err != nil {
+		return err
+	}
+	webhooks.ParticipantUpdated(participant)
+
+	return c.JSON(http.StatusOK, newAPIParticipant(participant, apiCredentialsAllowed(c)))
+}
+
+// apiParticipantDelete Delete participant and register the first one from the waitlist
+func apiParticipantDelete(c echo.Context) error {
+	contest, participant, err := apiParticipantParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.DeleteContestParticipant(participant.Id); err != nil {
+		return err
+	}
+	webhooks.ParticipantDeleted(participant)
+	if err := promoteWaitlisted(contest.Id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// newAPIParticipant Participant for JSON, login and password are empty without credentials
+func newAPIParticipant(participant *storage.ContestParticipant, credentials bool) apiParticipant {
+	answers := participant.Answers
+	if answers == nil {
+		answers = map[string]string{}
+	}
+	result := apiParticipant{
+		Id:             participant.Id,
+		ContestId:      participant.ContestId,
+		TelegramId:     participant.ParticipantId,
+		Name:           participant.Name,
+		Answers:        answers,
+		Room:           participant.Room,
+		Seat:           participant.Seat,
+		Waitlisted:     participant.Waitlisted,
+		InviteCode:     participant.InviteCode,
+		Members:        newAPITeamMembers(participant.Members),
+		ConsentAt:      apiTime(participant.ConsentAt),
+		ConsentVersion: participant.ConsentVersion,
+
+		Phone:            participant.Phone,
+		TelegramUsername: participant.TelegramUsername,
+		FirstName:        participant.FirstName,
+		LastName:         participant.LastName,
+	}
+	if credentials {
+		result.Login = participant.Login
+		result.Password = participant.Password
+	}
+	return result
+}
+
+// apiCredentialsAllowed Token user can see participant logins and passwords, volunteers can not
+func apiCredentialsAllowed(c echo.Context) bool {
+	user := currentUser(c)
+	return user != nil && user.IsOrganizer()
+}
+
+func newAPITeamMembers(members []storage.TeamMember) []apiTeamMember {
+	var result []apiTeamMember
+	for _, member := range members {
+		result = append(result, apiTeamMember{TelegramId: member.ChatId, Name: member.Name})
+	}
+	return result
+}
+
+// apply Copy editable values to the participant and save it
+func (participantData *apiParticipant) apply(lang string, contest *storage.Contest, participant *storage.ContestParticipant) error {
+	answers, err := contestAnswers(lang, contest, func(key string) string {
+		if answer, ok := participantData.Answers[key]; ok {
+			return answer
+		}
+		return participant.Answer(key)
+	})
+	if err != nil {
+		return apiBadRequest(err)
+	}
+
+	for key, value := range answers {
+		participant.SetAnswer(key, value)
+	}
+	participant.Login = strings.TrimSpace(participantData.Login)
+	participant.Password = strings.TrimSpace(participantData.Password)
+	participant.Room = strings.TrimSpace(participantData.Room)
+	participant.Seat = strings.TrimSpace(participantData.Seat)
+
+	if err := storage.SaveContestParticipant(participant); err != nil {
+		if errors.Is(err, storage.ErrLoginExists) {
+			return apiBadRequest(err)
+		}
+		return err
+	}
+	return nil
+}
+
+func apiParticipantParam(c echo.Context) (*storage.Contest, *storage.ContestParticipant, error) {
+	var id participantIdRequest
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, &id); err != nil {
+		return nil, nil, err
+	}
+
+	contest, err := storage.GetContest(id.ContestId)
+	if err != nil {
+		return nil, nil, err
+	}
+	participant, err := storage.GetContestParticipant(id.ParticipantId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if participant.ContestId != contest.Id {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, "participant does not belong to contest")
+	}
+
+	return contest, participant, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+//contest notifications
+
+// apiNotificationsList List contest notifications with delivery counters
+func apiNotificationsList(c echo.Context) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+
+	notifications, err := storage.GetContestNotifications(contest.Id)
+	if err != nil {
+		return err
+	}
+
+	result := make([]apiNotification, 0, len(notifications))
+	for _, notification := range notifications {
+		item, err := newAPINotification(requestLanguage(c), contest, &notification)
+		if err != nil {
+			return err
+		}
+		result = append(result, item)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// apiNotificationGet One contest notification
+func apiNotificationGet(c echo.Context) error {
+	contest, notification, err := apiNotificationParam(c)
+	if err != nil {
+		return err
+	}
+	return apiNotificationResponse(c, http.StatusOK, contest, notification)
+}
+
+// apiNotificationCreate Create notification, it is sent right away without send_at in the future
+func apiNotificationCreate(c echo.Context) error {
+	contest, err := apiContestParam(c)
+	if err != nil {
+		return err
+	}
+
+	var notificationData apiNotification
+	if err := (&echo.DefaultBinder{}).BindBody(c, &notificationData); err != nil {
+		return err
+	}
+	if len(notificationData.Message) == 0 {
+		return apiBadRequest(errors.New("notification message required"))
+	}
+
+	notification := &storage.ContestNotification{
+		ContestId: contest.Id,
+		Message:   notificationData.Message,
+	}
+	if err := scheduleNotification(notification, storageTime(notificationData.SendAt)); err != nil {
+		return err
+	}
+
+	return apiNotificationResponse(c, http.StatusCreated, contest, notification)
+}
+
+// apiNotificationUpdate Update notification message and sending time
+func apiNotificationUpdate(c echo.Context) error {
+	contest, notification, err := apiNotificationParam(c)
+	if err != nil {
+		return err
+	}
+
+	var notificationData apiNotification
+	if err := (&echo.DefaultBinder{}).BindBody(c, &notificationData); err != nil {
+		return err
+	}
+	if len(notificationData.Message) == 0 {
+		return apiBadRequest(errors.New("notification message required"))
+	}
+
+	//unchanged reminder text keeps its message key to stay in the language of each participant
+	if notificationData.Message != bot.NotificationText(requestLanguage(c), contest, notification) {
+		notification.Message = notificationData.Message
+	}
+	if err := scheduleNotification(notification, storageTime(notificationData.SendAt)); err != nil {
+		return err
+	}
+
+	return apiNotificationResponse(c, http.StatusOK, contest, notification)
+}
+
+// apiNotificationDelete Delete notification and its deliveries
+func apiNotificationDelete(c echo.Context) error {
+	_, notification, err := apiNotificationParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.DeleteNotificationDeliveries(notification.Id); err != nil {
+		return err
+	}
+	if err := storage.DeleteContestNotification(notification.Id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func apiNotificationResponse(c echo.Context, status int, contest *storage.Contest, notification *storage.ContestNotification) error {
+	result, err := newAPINotification(requestLanguage(c), contest, notification)
+	if err != nil {
+		return err
+	}
+	return c.JSON(status, result)
+}
+
+// newAPINotification Notification for JSON, reminder message is rendered in given language
+func newAPINotification(lang string, contest *storage.Contest, notification *storage.ContestNotification) (apiNotification, error) {
+	result := apiNotification{
+		Id:        notification.Id,
+		ContestId: notification.ContestId,
+		Message:   bot.NotificationText(lang, contest, notification),
+		SendAt:    apiTime(notification.SendAt),
+		SentAt:    apiTime(notification.SentAt),
+		Reminder:  notification.IsReminder(),
+	}
+
+	deliveries, err := storage.GetNotificationDeliveries(notification.Id)
+	if err != nil {
+		return result, err
+	}
+	for _, delivery := range deliveries {
+		switch delivery.Status {
+		case storage.DeliveryStatusPending:
+			result.Deliveries.Pending++
+		case storage.DeliveryStatusSent:
+			result.Deliveries.Sent++
+		case storage.DeliveryStatusFailed:
+			result.Deliveries.Failed++
+		case storage.DeliveryStatusBlocked:
+			result.Deliveries.Blocked++
+		}
+	}
+
+	return result, nil
+}
+
+func apiNotificationParam(c echo.Context) (*storage.Contest, *storage.ContestNotification, error) {
+	var id notificationIdRequest
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, &id); err != nil {
+		return nil, nil, err
+	}
+
+	contest, err := storage.GetContest(id.ContestId)
+	if err != nil {
+		return nil, nil, err
+	}
+	notification, err := storage.GetContestNotification(id.NotificationId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if notification.ContestId != contest.Id {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, "notification belongs to other contest")
+	}
+
+	return contest, notification, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// apiTime Time for JSON, null when not set
+func apiTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// storageTime Time from JSON, zero when not set
+func storageTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -0,0 +1,187 @@
+package web
+
+import (
+	"contest-registration-bot/storage"
+	"crypto/subtle"
+	"errors"
+	"github.com/flosch/pongo2/v4"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+const (
+	sessionCookieName = "session"
+	csrfFormField     = "csrf_token"
+	csrfHeader        = "X-CSRF-Token"
+
+	contextUserKey    = "user"
+	contextSessionKey = "session"
+)
+
+type loginRequest struct {
+	Login    string `form:"login"`
+	Password string `form:"password"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// loginGet Login form
+func loginGet(c echo.Context) error {
+	return c.Render(http.StatusOK, "templates/login.twig", pongo2.Context{})
+}
+
+// loginPost Check credentials and start new session
+func loginPost(c echo.Context) error {
+	var loginData loginRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &loginData); err != nil {
+		return err
+	}
+
+	user, err := storage.GetUserByLogin(loginData.Login)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.CheckPassword(loginData.Password) {
+		log.Warnf("failed login attempt: login=%s, ip=%s", loginData.Login, c.RealIP())
+		return c.Render(http.StatusUnauthorized, "templates/login.twig", pongo2.Context{
+			"login":      loginData.Login,
+			"page_error": errors.New(t(c, "web.errors.login_failed")),
+		})
+	}
+
+	session, err := storage.CreateSession(user.Id, configuration.SessionLifetime)
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.Id,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   configuration.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return c.Redirect(http.StatusFound, "/")
+}
+
+// logout Finish current session
+func logout(c echo.Context) error {
+	if session := currentSession(c); session != nil {
+		if err := storage.DeleteSession(session.Id); err != nil {
+			return err
+		}
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   configuration.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return c.Redirect(http.StatusFound, "/login")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// authenticate Middleware requiring logged-in admin user
+func authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, session, err := sessionUser(c)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			if c.Request().Method == http.MethodGet {
+				return c.Redirect(http.StatusFound, "/login")
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, t(c, "web.errors.unauthorized"))
+		}
+
+		c.Set(contextUserKey, user)
+		c.Set(contextSessionKey, session)
+
+		return next(c)
+	}
+}
+
+// verifyCSRF Middleware checking CSRF token of state-changing requests
+func verifyCSRF(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+
+		session, ok := c.Get(contextSessionKey).(*storage.Session)
+		if !ok {
+			return echo.NewHTTPError(http.StatusForbidden, t(c, "web.errors.csrf"))
+		}
+
+		token := c.Request().Header.Get(csrfHeader)
+		if len(token) == 0 {
+			token = c.FormValue(csrfFormField)
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+			log.Warnf("CSRF token mismatch: method=%s, url=%s, ip=%s", c.Request().Method, c.Request().URL, c.RealIP())
+			return echo.NewHTTPError(http.StatusForbidden, t(c, "web.errors.csrf"))
+		}
+
+		return next(c)
+	}
+}
+
+// requireOrganizer Middleware allowing access only to organizers
+func requireOrganizer(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := currentUser(c)
+		if user == nil || !user.IsOrganizer() {
+			return echo.NewHTTPError(http.StatusForbidden, t(c, "web.errors.forbidden"))
+		}
+		return next(c)
+	}
+}
+
+// currentSession Session of logged-in admin user
+func currentSession(c echo.Context) *storage.Session {
+	session, _ := c.Get(contextSessionKey).(*storage.Session)
+	return session
+}
+
+// currentUser Logged-in admin user
+func currentUser(c echo.Context) *storage.User {
+	user, _ := c.Get(contextUserKey).(*storage.User)
+	return user
+}
+
+func sessionUser(c echo.Context) (*storage.User, *storage.Session, error) {
+	cookie, err := c.Cookie(sessionCookieName)
+	if err != nil || len(cookie.Value) == 0 {
+		return nil, nil, nil
+	}
+
+	session, err := storage.GetSession(cookie.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil {
+		return nil, nil, nil
+	}
+
+	user, err := storage.GetUser(session.UserId)
+	if err != nil {
+		log.Warnf("session %s of unknown user %d", session.Id, session.UserId)
+		return nil, nil, nil
+	}
+
+	return user, session, nil
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	"fmt"
+	"github.com/flosch/pongo2/v4"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "20060102-150405"
+
+// dataGet Backup, export and import page
+func dataGet(c echo.Context) error {
+	return c.Render(http.StatusOK, "templates/data.twig", pongo2.Context{})
+}
+
+// backupGet Download consistent copy of the database
+func backupGet(c echo.Context) error {
+	size, err := storage.BackupSize()
+	if err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("bolt-%s.db", time.Now().Format(backupTimeFormat))
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	response.Header().Set(echo.HeaderContentDisposition, "attachment; filename=\""+fileName+"\"")
+	response.Header().Set(echo.HeaderContentLength, strconv.FormatInt(size, 10))
+	response.WriteHeader(http.StatusOK)
+
+	if _, err := storage.WriteBackup(response); err != nil {
+		//headers are already sent, error page can't be rendered
+		log.Errorf("database backup error: %s", err)
+	}
+	return nil
+}
+
+// exportGet Download JSON export of all contests and registrations
+func exportGet(c echo.Context) error {
+	fileName := fmt.Sprintf("contests-%s.json", time.Now().Format(backupTimeFormat))
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	response.Header().Set(echo.HeaderContentDisposition, "attachment; filename=\""+fileName+"\"")
+	response.WriteHeader(http.StatusOK)
+
+	if err := storage.WriteExport(response); err != nil {
+		log.Errorf("database export error: %s", err)
+	}
+	return nil
+}
+
+// importPost Import JSON export uploaded from other instance
+func importPost(c echo.Context) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return errors.New("export file required")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	replace := c.FormValue("replace") == "1"
+
+	result, err := storage.ReadExport(file, replace)
+	if err != nil {
+		return fmt.Errorf("import failed: %s", err)
+	}
+
+	log.Infof("data imported by %s: %d contests, %d participants, %d notifications, %d dialog states",
+		currentUser(c).Login, result.Contests, result.Participants, result.Notifications, result.DialogStates)
+
+	return c.Render(http.StatusOK, "templates/data.twig", pongo2.Context{
+		"import_result": result,
+	})
+}
+
+// forgetPost Erase personal data of Telegram chat on participant's request
+func forgetPost(c echo.Context) error {
+	chatId, err := strconv.ParseInt(strings.TrimSpace(c.FormValue("chat_id")), 10, 64)
+	if err != nil || chatId == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, t(c, "web.data.forget_invalid"))
+	}
+
+	result, err := storage.AnonymizeChat(chatId)
+	if err != nil {
+		return err
+	}
+	for _, participant := range result.Participants {
+		webhooks.ParticipantUpdated(&participant)
+	}
+
+	log.Infof("chat %d data erased by %s: %d registrations, %d notification deliveries, %d webhook deliveries",
+		chatId, currentUser(c).Login, len(result.Participants), result.Deliveries, result.WebhookDeliveries)
+
+	return c.Render(http.StatusOK, "templates/data.twig", pongo2.Context{
+		"forget_chat_id": chatId,
+		"forget_result":  result,
+	})
+}
@@ -0,0 +1,341 @@
+package web
+
+import (
+	"contest-registration-bot/storage"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// participantExporter Participants export format for judge systems
+type participantExporter struct {
+	Key string
+	// Title Message key of format title
+	Title       string
+	FileName    string
+	ContentType string
+	Write       func(w io.Writer, contest *storage.Contest, participants []exportParticipant) error
+}
+
+// exportParticipant Participant data mapped to fields common for judge systems
+type exportParticipant struct {
+	Login        string
+	Password     string
+	Name         string
+	Organization string
+	Email        string
+	Phone        string
+	Participant  *storage.ContestParticipant
+}
+
+var (
+	exporters      []*participantExporter
+	exportersByKey = make(map[string]*participantExporter)
+)
+
+// organizationFieldKeys Form field keys holding participant's school or university
+var organizationFieldKeys = []string{"school", "organization", "university", "inst"}
+
+// registerExporter Add participants export format, formats are shown in order of registration
+func registerExporter(exporter *participantExporter) {
+	if _, ok := exportersByKey[exporter.Key]; ok {
+		panic("duplicate participants exporter: " + exporter.Key)
+	}
+	exporters = append(exporters, exporter)
+	exportersByKey[exporter.Key] = exporter
+}
+
+func init() {
+	registerExporter(&participantExporter{
+		Key:         "csv",
+		Title:       "web.export.csv",
+		FileName:    "participants.csv",
+		ContentType: "text/csv",
+		Write:       exportCSV,
+	})
+	registerExporter(&participantExporter{
+		Key:         "domjudge-accounts",
+		Title:       "web.export.domjudge_accounts",
+		FileName:    "accounts.tsv",
+		ContentType: "text/tab-separated-values",
+		Write:       exportDOMjudgeAccounts,
+	})
+	registerExporter(&participantExporter{
+		Key:         "domjudge-teams",
+		Title:       "web.export.domjudge_teams",
+		FileName:    "teams.json",
+		ContentType: "application/json",
+		Write:       exportDOMjudgeTeams,
+	})
+	registerExporter(&participantExporter{
+		Key:         "ejudge",
+		Title:       "web.export.ejudge",
+		FileName:    "users.xml",
+		ContentType: "application/xml",
+		Write:       exportEjudge,
+	})
+	registerExporter(&participantExporter{
+		Key:         "pcms2",
+		Title:       "web.export.pcms2",
+		FileName:    "parties.xml",
+		ContentType: "application/xml",
+		Write:       exportPCMS2,
+	})
+	registerExporter(&participantExporter{
+		Key:         "yandex-contest",
+		Title:       "web.export.yandex_contest",
+		FileName:    "yandex-contest.csv",
+		ContentType: "text/csv",
+		Write:       exportYandexContest,
+	})
+}
+
+// exportParticipants Registered participants (without waitlist) with mapped fields
+func exportParticipants(contest *storage.Contest, participants []storage.ContestParticipant) []exportParticipant {
+	fields := contest.FormFields()
+
+	var exported []exportParticipant
+
+	for i := range participants {
+		participant := &participants[i]
+		if participant.Waitlisted {
+			continue
+		}
+
+		item := exportParticipant{
+			Login:       participant.Login,
+			Password:    participant.Password,
+			Name:        participant.Name,
+			Participant: participant,
+		}
+		for _, key := range organizationFieldKeys {
+			if answer := participant.Answer(key); len(answer) != 0 {
+				item.Organization = answer
+				break
+			}
+		}
+		for _, field := range fields {
+			answer := participant.Answer(field.Key)
+			if field.Type == storage.FormFieldTypeEmail && len(item.Email) == 0 {
+				item.Email = answer
+			}
+			if field.Type == storage.FormFieldTypePhone && len(item.Phone) == 0 {
+				item.Phone = answer
+			}
+		}
+		if len(item.Phone) == 0 {
+			item.Phone = participant.Phone
+		}
+
+		exported = append(exported, item)
+	}
+
+	return exported
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// exportCSV login;password;name, answers to all other form fields, room and seat,
+// team members in team mode, Telegram profile and shared phone
+func exportCSV(w io.Writer, contest *storage.Contest, participants []exportParticipant) error {
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Comma = ';'
+	csvWriter.UseCRLF = false
+
+	fields := contest.FormFields()
+
+	header := []string{"login", "password", "name"}
+	for _, field := range fields {
+		if field.Key != storage.FormFieldName {
+			header = append(header, field.Key)
+		}
+	}
+	header = append(header, "room", "seat")
+	if contest.TeamMode {
+		header = append(header, "members")
+	}
+	header = append(header, "telegram_username", "telegram_first_name", "telegram_last_name", "telegram_phone")
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	for _, participant := range participants {
+		record := []string{participant.Login, participant.Password, participant.Name}
+		for _, field := range fields {
+			if field.Key != storage.FormFieldName {
+				record = append(record, participant.Participant.Answer(field.Key))
+			}
+		}
+		record = append(record, participant.Participant.Room, participant.Participant.Seat)
+		if contest.TeamMode {
+			record = append(record, participant.Participant.MembersText())
+		}
+		record = append(record, participant.Participant.TelegramUsername, participant.Participant.FirstName,
+			participant.Participant.LastName, participant.Participant.Phone)
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// exportDOMjudgeAccounts DOMjudge accounts.tsv: type, full name, username, password
+func exportDOMjudgeAccounts(w io.Writer, contest *storage.Contest, participants []exportParticipant) error {
+	if _, err := fmt.Fprint(w, "accounts\t1\n"); err != nil {
+		return err
+	}
+	for _, participant := range participants {
+		_, err := fmt.Fprintf(w, "team\t%s\t%s\t%s\n",
+			tsvValue(participant.Name), tsvValue(participant.Login), tsvValue(participant.Password))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type domjudgeTeam struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name"`
+	GroupIds    []string `json:"group_ids"`
+	Affiliation string   `json:"affiliation,omitempty"`
+}
+
+// exportDOMjudgeTeams DOMjudge teams.json, team ids are participant logins
+func exportDOMjudgeTeams(w io.Writer, contest *storage.Contest, participants []exportParticipant) error {
+	teams := make([]domjudgeTeam, 0, len(participants))
+	for _, participant := range participants {
+		teams = append(teams, domjudgeTeam{
+			Id:          participant.Login,
+			Name:        participant.Login,
+			DisplayName: participant.Name,
+			GroupIds:    []string{"participants"},
+			Affiliation: participant.Organization,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(teams)
+}
+
+type ejudgeUserList struct {
+	XMLName xml.Name     `xml:"userlist"`
+	Users   []ejudgeUser `xml:"users>user"`
+}
+
+type ejudgeUser struct {
+	Login     string           `xml:"login,attr"`
+	Password  ejudgePassword   `xml:"password"`
+	Email     string           `xml:"email,omitempty"`
+	Contests  []ejudgeContest  `xml:"contests>contest"`
+	CntsInfos []ejudgeCntsInfo `xml:"cntsinfos>cntsinfo"`
+}
+
+type ejudgePassword struct {
+	Method string `xml:"method,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type ejudgeContest struct {
+	Id     uint64 `xml:"id,attr"`
+	Status string `xml:"status,attr"`
+}
+
+type ejudgeCntsInfo struct {
+	ContestId uint64 `xml:"contest_id,attr"`
+	Name      string `xml:"name"`
+	Inst      string `xml:"inst,omitempty"`
+	Phone     string `xml:"phone,omitempty"`
+}
+
+// exportEjudge ejudge userlist XML with plain text passwords,
+// contest id should be changed to ejudge contest id before import
+func exportEjudge(w io.Writer, contest *storage.Contest, participants []exportParticipant) error {
+	list := ejudgeUserList{}
+	for _, participant := range participants {
+		list.Users = append(list.Users, ejudgeUser{
+			Login:    participant.Login,
+			Password: ejudgePassword{Method: "plain", Value: participant.Password},
+			Email:    participant.Email,
+			Contests: []ejudgeContest{{Id: contest.Id, Status: "ok"}},
+			CntsInfos: []ejudgeCntsInfo{{
+				ContestId: contest.Id,
+				Name:      participant.Name,
+				Inst:      participant.Organization,
+				Phone:     participant.Phone,
+			}},
+		})
+	}
+	return writeXML(w, list)
+}
+
+type pcms2PartyList struct {
+	XMLName xml.Name     `xml:"parties"`
+	Parties []pcms2Party `xml:"party"`
+}
+
+type pcms2Party struct {
+	Id       string `xml:"id,attr"`
+	Password string `xml:"password,attr"`
+	Name     string `xml:"name,attr"`
+	Region   string `xml:"region,attr,omitempty"`
+}
+
+// exportPCMS2 PCMS2 party list, party ids are participant logins
+func exportPCMS2(w io.Writer, contest *storage.Contest, participants []exportParticipant) error {
+	list := pcms2PartyList{}
+	for _, participant := range participants {
+		list.Parties = append(list.Parties, pcms2Party{
+			Id:       participant.Login,
+			Password: participant.Password,
+			Name:     participant.Name,
+			Region:   participant.Organization,
+		})
+	}
+	return writeXML(w, list)
+}
+
+// exportYandexContest Yandex.Contest participants list: login, password, display name
+func exportYandexContest(w io.Writer, contest *storage.Contest, participants []exportParticipant) error {
+	csvWriter := csv.NewWriter(w)
+	csvWriter.UseCRLF = false
+
+	if err := csvWriter.Write([]string{"login", "password", "name"}); err != nil {
+		return err
+	}
+	for _, participant := range participants {
+		if err := csvWriter.Write([]string{participant.Login, participant.Password, participant.Name}); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func writeXML(w io.Writer, value interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// tsvValue Value without tabs and line breaks
+func tsvValue(value string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(value)
+}
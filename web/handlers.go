@@ -1,37 +1,100 @@
 package web
 
 import (
+	"bytes"
+	"contest-registration-bot/bot"
+	"contest-registration-bot/i18n"
 	"contest-registration-bot/storage"
-	"encoding/csv"
+	"contest-registration-bot/webhooks"
 	"errors"
 	"fmt"
 	"github.com/flosch/pongo2/v4"
 	"github.com/labstack/echo/v4"
+	"github.com/skip2/go-qrcode"
 	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	defaultFormFieldMaxLength = 200
+	dateTimeFormat            = "2006-01-02T15:04"
+	dateTimeSecondsFormat     = "2006-01-02T15:04:05"
+	maxChoiceRange            = 100
+)
+
+var (
+	formFieldKeyRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)
+	choiceRangeRegexp  = regexp.MustCompile(`^(\d+)\s*[-–]\s*(\d+)$`)
 )
 
 type contestRequest struct {
-	Id          uint64 `form:"id"`
-	Name        string `form:"name"`
-	Description string `form:"description"`
-	When        string `form:"when"`
-	Where       string `form:"where"`
+	Id              uint64   `form:"id"`
+	Name            string   `form:"name"`
+	Description     string   `form:"description"`
+	Note            string   `form:"note"`
+	Where           string   `form:"where"`
+	Capacity        int      `form:"capacity"`
+	TeamMode        string   `form:"team_mode"`
+	TeamSize        int      `form:"team_size"`
+	StartsAt        string   `form:"starts_at"`
+	EndsAt          string   `form:"ends_at"`
+	Deadline        string   `form:"registration_deadline"`
+	Timezone        string   `form:"timezone"`
+	Reminders       []int    `form:"reminders"`
+	LoginPrefix     string   `form:"login_prefix"`
+	LoginSequential string   `form:"login_sequential"`
+	LoginDigits     int      `form:"login_digits"`
+	LoginLength     int      `form:"login_length"`
+	PasswordLength  int      `form:"password_length"`
+	PasswordAlpha   string   `form:"password_alphabet"`
+	NoAmbiguous     string   `form:"exclude_ambiguous"`
+	FieldKeys       []string `form:"field_key"`
+	FieldTitles     []string `form:"field_title"`
+	FieldPrompts    []string `form:"field_prompt"`
+	FieldExamples   []string `form:"field_example"`
+	FieldMaxLengths []int    `form:"field_max_length"`
+	FieldRequired   []string `form:"field_required"`
+	FieldTypes      []string `form:"field_type"`
+	FieldPatterns   []string `form:"field_pattern"`
+	FieldChoices    []string `form:"field_choices"`
+	FieldPositions  []int    `form:"field_position"`
 }
 
 type participantRequest struct {
-	Id        uint64 `form:"participant_id"`
-	Name      string `form:"name"`
-	School    string `form:"school"`
-	Contacts  string `form:"contacts"`
-	Languages string `form:"languages"`
-	Login     string `form:"login"`
-	Password  string `form:"password"`
+	Id          uint64   `form:"participant_id"`
+	Login       string   `form:"login"`
+	Password    string   `form:"password"`
+	Room        string   `form:"room"`
+	Seat        string   `form:"seat"`
+	MemberChats []int64  `form:"member_chat"`
+	MemberNames []string `form:"member_name"`
 }
 
 type notificationRequest struct {
 	Id      uint64 `form:"notification_id"`
 	Message string `form:"message"`
+	SendAt  string `form:"send_at"`
+}
+
+type notificationView struct {
+	Notification storage.ContestNotification
+	// Message Notification text, reminders in the language of admin panel
+	Message    string
+	Deliveries []deliveryView
+	Pending    int
+	Sent       int
+	Failed     int
+	Blocked    int
+}
+
+type deliveryView struct {
+	Delivery        storage.NotificationDelivery
+	ParticipantName string
 }
 
 type idRequest struct {
@@ -66,7 +129,15 @@ func contestsGet(c echo.Context) error {
 // contestNew Form to create new contest
 func contestNew(c echo.Context) error {
 	return c.Render(http.StatusOK, "templates/contest.twig", pongo2.Context{
-		"contest": nil,
+		"contest":            nil,
+		"fields":             storage.DefaultFormFields(requestLanguage(c)),
+		"field_types":        storage.FormFieldTypes(),
+		"reminder_templates": storage.ReminderTemplates(),
+		"timezones":          storage.ContestTimezones(),
+		"credentials":        storage.DefaultCredentialPolicy(),
+		"password_alphabets": storage.PasswordAlphabets(),
+		"default_team_size":  storage.DefaultTeamSize,
+		"max_team_size":      storage.MaxTeamSize,
 	})
 }
 
@@ -78,31 +149,79 @@ func contestGet(c echo.Context) error {
 	}
 
 	return c.Render(http.StatusOK, "templates/contest.twig", pongo2.Context{
-		"contest": contest,
+		"contest":            contest,
+		"fields":             contest.FormFields(),
+		"field_types":        storage.FormFieldTypes(),
+		"reminder_templates": storage.ReminderTemplates(),
+		"timezones":          storage.ContestTimezones(),
+		"credentials":        contest.CredentialPolicy(),
+		"password_alphabets": storage.PasswordAlphabets(),
+		"default_team_size":  storage.DefaultTeamSize,
+		"max_team_size":      storage.MaxTeamSize,
+		"contest_link":       registrationBot.ContestLink(contest.Id),
 	})
 }
 
+// contestLinkQR QR code of contest registration link for posters
+func contestLinkQR(c echo.Context) error {
+	contest, err := contest(c)
+	if err != nil {
+		return err
+	}
+
+	png, err := qrcode.Encode(registrationBot.ContestLink(contest.Id), qrcode.Medium, contestLinkQRSize)
+	if err != nil {
+		return fmt.Errorf("unable to generate QR code: %s", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("inline; filename=\"contest-%d.png\"", contest.Id))
+	return c.Blob(http.StatusOK, "image/png", png)
+}
+
 // contestSave Save new or update existing contest
 func contestSave(c echo.Context) error {
 	var contestData contestRequest
 	if err := (&echo.DefaultBinder{}).BindBody(c, &contestData); err != nil {
 		return err
 	}
-	if len(contestData.Name) == 0 {
-		return errors.New("contest name required")
+	fields, err := contestFormFields(&contestData)
+	if err != nil {
+		return err
 	}
-	if len(contestData.Description) == 0 {
-		return errors.New("contest description required")
+	location := time.Local
+	if len(contestData.Timezone) != 0 {
+		location, err = time.LoadLocation(contestData.Timezone)
+		if err != nil {
+			return fmt.Errorf("unknown contest timezone: %s", contestData.Timezone)
+		}
 	}
-	if len(contestData.Where) == 0 {
-		return errors.New("contest location required")
+	startsAt, err := parseDateTime(contestData.StartsAt, location)
+	if err != nil {
+		return fmt.Errorf("contest start time: %s", err)
 	}
-	if len(contestData.When) == 0 {
-		return errors.New("contest date required")
+	endsAt, err := parseDateTime(contestData.EndsAt, location)
+	if err != nil {
+		return fmt.Errorf("contest end time: %s", err)
+	}
+	deadline, err := parseDateTime(contestData.Deadline, location)
+	if err != nil {
+		return fmt.Errorf("contest registration deadline: %s", err)
+	}
+	credentials := storage.CredentialPolicy{
+		LoginPrefix:      strings.TrimSpace(contestData.LoginPrefix),
+		Sequential:       contestData.LoginSequential == "1",
+		SequenceDigits:   contestData.LoginDigits,
+		LoginLength:      contestData.LoginLength,
+		PasswordLength:   contestData.PasswordLength,
+		PasswordAlphabet: contestData.PasswordAlpha,
+		ExcludeAmbiguous: contestData.NoAmbiguous == "1",
+	}
+	var reminders []time.Duration
+	for _, minutes := range contestData.Reminders {
+		reminders = append(reminders, time.Duration(minutes)*time.Minute)
 	}
 
 	var contest *storage.Contest
-	var err error
 
 	if contestData.Id != 0 {
 		contest, err = storage.GetContest(contestData.Id)
@@ -111,20 +230,43 @@ func contestSave(c echo.Context) error {
 		}
 		contest.Name = contestData.Name
 		contest.Description = contestData.Description
-		contest.When = contestData.When
+		contest.Note = contestData.Note
 		contest.Where = contestData.Where
+		contest.Capacity = contestData.Capacity
+		contest.TeamMode = contestData.TeamMode == "1"
+		contest.TeamSize = contestData.TeamSize
+		contest.Fields = fields
+		contest.StartsAt = startsAt
+		contest.EndsAt = endsAt
+		contest.RegistrationDeadline = deadline
+		contest.Timezone = contestData.Timezone
+		contest.Reminders = reminders
+		contest.Credentials = credentials
 	} else {
 		contest = &storage.Contest{
-			Name:        contestData.Name,
-			Description: contestData.Description,
-			When:        contestData.When,
-			Where:       contestData.Where,
-			Closed:      false,
-			Hidden:      false,
+			Name:                 contestData.Name,
+			Description:          contestData.Description,
+			Note:                 contestData.Note,
+			Where:                contestData.Where,
+			Closed:               false,
+			Hidden:               false,
+			Capacity:             contestData.Capacity,
+			TeamMode:             contestData.TeamMode == "1",
+			TeamSize:             contestData.TeamSize,
+			Fields:               fields,
+			StartsAt:             startsAt,
+			EndsAt:               endsAt,
+			RegistrationDeadline: deadline,
+			Timezone:             contestData.Timezone,
+			Reminders:            reminders,
+			Credentials:          credentials,
 		}
 	}
 
-	if err := storage.SaveContest(contest); err != nil {
+	if err := validateContest(contest); err != nil {
+		return err
+	}
+	if err := saveContest(contest); err != nil {
 		return err
 	}
 
@@ -151,17 +293,228 @@ func contestShow(c echo.Context) error {
 	return contestUpdateHidden(false, c)
 }
 
+// contestFormFields Registration form fields from the contest form, ordered by position
+func contestFormFields(contestData *contestRequest) ([]storage.FormField, error) {
+	type positionedField struct {
+		field    storage.FormField
+		position int
+	}
+
+	var positioned []positionedField
+
+	for i, key := range contestData.FieldKeys {
+		key = strings.TrimSpace(key)
+		if len(key) == 0 {
+			continue
+		}
+
+		field := storage.FormField{
+			Key:       key,
+			Title:     strings.TrimSpace(formValue(contestData.FieldTitles, i)),
+			Prompt:    strings.TrimSpace(formValue(contestData.FieldPrompts, i)),
+			Example:   strings.TrimSpace(formValue(contestData.FieldExamples, i)),
+			MaxLength: formIntValue(contestData.FieldMaxLengths, i),
+			Required:  formValue(contestData.FieldRequired, i) == "1",
+			Type:      formValue(contestData.FieldTypes, i),
+			Pattern:   formValue(contestData.FieldPatterns, i),
+			Choices:   parseChoices(formValue(contestData.FieldChoices, i)),
+		}
+		normalizeFormField(&field)
+
+		positioned = append(positioned, positionedField{
+			field:    field,
+			position: formIntValue(contestData.FieldPositions, i),
+		})
+	}
+
+	sort.SliceStable(positioned, func(i, j int) bool {
+		return positioned[i].position < positioned[j].position
+	})
+
+	fields := make([]storage.FormField, len(positioned))
+	for i, item := range positioned {
+		fields[i] = item.field
+	}
+
+	return fields, nil
+}
+
+// normalizeFormField Fill default title and max length, name is always required
+func normalizeFormField(field *storage.FormField) {
+	field.Key = strings.TrimSpace(field.Key)
+	field.Title = strings.TrimSpace(field.Title)
+	field.Prompt = strings.TrimSpace(field.Prompt)
+	field.Example = strings.TrimSpace(field.Example)
+	field.Pattern = strings.TrimSpace(field.Pattern)
+	if field.Type != storage.FormFieldTypeChoice {
+		field.Choices = nil
+	}
+	if len(field.Title) == 0 {
+		field.Title = field.Key
+	}
+	if field.MaxLength <= 0 {
+		field.MaxLength = defaultFormFieldMaxLength
+	}
+	if field.Key == storage.FormFieldName {
+		field.Required = true
+	}
+}
+
+// parseChoices Comma separated answers of choice field, numeric ranges like 1-11 are expanded
+func parseChoices(value string) []string {
+	var choices []string
+	for _, choice := range strings.Split(value, ",") {
+		choice = strings.TrimSpace(choice)
+		if len(choice) == 0 {
+			continue
+		}
+		if match := choiceRangeRegexp.FindStringSubmatch(choice); match != nil {
+			from, _ := strconv.Atoi(match[1])
+			to, _ := strconv.Atoi(match[2])
+			if from <= to && to-from < maxChoiceRange {
+				for i := from; i <= to; i++ {
+					if !slices.Contains(choices, strconv.Itoa(i)) {
+						choices = append(choices, strconv.Itoa(i))
+					}
+				}
+				continue
+			}
+		}
+		if !slices.Contains(choices, choice) {
+			choices = append(choices, choice)
+		}
+	}
+	return choices
+}
+
+// validateFormFields Check keys, prompts and types of registration form fields
+func validateFormFields(fields []storage.FormField) error {
+	keys := make(map[string]bool)
+
+	for _, field := range fields {
+		if !formFieldKeyRegexp.MatchString(field.Key) {
+			return fmt.Errorf("form field key \"%s\" must contain only latin letters, digits and underscores", field.Key)
+		}
+		if keys[field.Key] {
+			return fmt.Errorf("form field key \"%s\" is not unique", field.Key)
+		}
+		keys[field.Key] = true
+
+		if len(field.Prompt) == 0 {
+			return fmt.Errorf("form field \"%s\" prompt required", field.Key)
+		}
+		if !slices.Contains(storage.FormFieldTypes(), field.Type) {
+			return fmt.Errorf("form field \"%s\" has unknown type \"%s\"", field.Key, field.Type)
+		}
+		if field.Type == storage.FormFieldTypeChoice && len(field.Choices) == 0 {
+			return fmt.Errorf("form field \"%s\" choices required", field.Key)
+		}
+		if _, err := field.PatternRegexp(); err != nil {
+			return fmt.Errorf("form field \"%s\" has invalid pattern: %s", field.Key, err)
+		}
+	}
+
+	if !keys[storage.FormFieldName] {
+		return fmt.Errorf("form field with key \"%s\" required", storage.FormFieldName)
+	}
+
+	return nil
+}
+
+// validateContest Check contest data entered in the admin form or sent to the API
+func validateContest(contest *storage.Contest) error {
+	if len(contest.Name) == 0 {
+		return errors.New("contest name required")
+	}
+	if len(contest.Description) == 0 {
+		return errors.New("contest description required")
+	}
+	if len(contest.Where) == 0 {
+		return errors.New("contest location required")
+	}
+	if contest.Capacity < 0 {
+		return errors.New("contest capacity must not be negative")
+	}
+	if contest.TeamSize < 0 || contest.TeamSize > storage.MaxTeamSize {
+		return fmt.Errorf("team size must be from 1 to %d", storage.MaxTeamSize)
+	}
+	if err := validateFormFields(contest.Fields); err != nil {
+		return err
+	}
+	if len(contest.Timezone) != 0 {
+		if _, err := time.LoadLocation(contest.Timezone); err != nil {
+			return fmt.Errorf("unknown contest timezone: %s", contest.Timezone)
+		}
+	}
+	if contest.StartsAt.IsZero() && len(contest.Note) == 0 {
+		return errors.New("contest start time required")
+	}
+	if !contest.EndsAt.IsZero() && (contest.StartsAt.IsZero() || !contest.EndsAt.After(contest.StartsAt)) {
+		return errors.New("contest end time must be after start time")
+	}
+	for _, reminder := range contest.Reminders {
+		if reminder <= 0 {
+			return errors.New("reminder must be before contest start")
+		}
+	}
+	return contest.Credentials.Validate()
+}
+
+// saveContest Save contest, update its reminders and register waitlisted participants to free places
+func saveContest(contest *storage.Contest) error {
+	if err := storage.SaveContest(contest); err != nil {
+		return err
+	}
+	if err := storage.SyncContestReminders(contest); err != nil {
+		return err
+	}
+	return promoteWaitlisted(contest.Id)
+}
+
+// parseDateTime Parse datetime-local form value in given timezone, empty value is zero time
+func parseDateTime(value string, location *time.Location) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(dateTimeFormat, value, location); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(dateTimeSecondsFormat, value, location)
+}
+
+func formValue(values []string, index int) string {
+	if index < len(values) {
+		return values[index]
+	}
+	return ""
+}
+
+func formIntValue(values []int, index int) int {
+	if index < len(values) {
+		return values[index]
+	}
+	return 0
+}
+
 func contestUpdateClosed(value bool, c echo.Context) error {
 	contest, err := contest(c)
 	if err != nil {
 		return err
 	}
 
+	if !value && !contest.RegistrationDeadline.IsZero() && !time.Now().Before(contest.RegistrationDeadline) {
+		return errors.New("registration deadline passed, change it to open registration")
+	}
+
+	changed := contest.Closed != value
 	contest.Closed = value
 
 	if err := storage.SaveContest(contest); err != nil {
 		return err
 	}
+	if changed {
+		contestClosedEvent(contest)
+	}
 
 	return c.Redirect(http.StatusFound, "/")
 }
@@ -213,9 +566,21 @@ func participantsList(c echo.Context) error {
 		return err
 	}
 
+	var registered, waitlist []*storage.ContestParticipant
+	for i := range participants {
+		if participants[i].Waitlisted {
+			waitlist = append(waitlist, &participants[i])
+		} else {
+			registered = append(registered, &participants[i])
+		}
+	}
+
 	return c.Render(http.StatusOK, "templates/participants.twig", pongo2.Context{
 		"contest":      contest,
-		"participants": participants,
+		"fields":       contest.FormFields(),
+		"participants": registered,
+		"waitlist":     waitlist,
+		"exporters":    exporters,
 	})
 }
 
@@ -226,30 +591,27 @@ func participantsExport(c echo.Context) error {
 		return err
 	}
 
+	format := c.QueryParam("format")
+	if len(format) == 0 {
+		format = exporters[0].Key
+	}
+	exporter, ok := exportersByKey[format]
+	if !ok {
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+
 	participants, err := storage.GetContestParticipants(contest.Id)
 	if err != nil {
 		return err
 	}
 
-	stringBuilder := &strings.Builder{}
-	csvWriter := csv.NewWriter(stringBuilder)
-	csvWriter.Comma = ';'
-	csvWriter.UseCRLF = false
-
-	if err := csvWriter.Write([]string{"login", "password", "name"}); err != nil {
+	buffer := &bytes.Buffer{}
+	if err := exporter.Write(buffer, contest, exportParticipants(contest, participants)); err != nil {
 		return err
 	}
 
-	for _, participant := range participants {
-		if err := csvWriter.Write([]string{participant.Login, participant.Password, participant.Name}); err != nil {
-			return err
-		}
-	}
-
-	csvWriter.Flush()
-
-	c.Response().Header().Set("Content-Disposition", "attachment; filename=\"participants.csv\"")
-	return c.Blob(http.StatusOK, "text/csv", []byte(stringBuilder.String()))
+	c.Response().Header().Set("Content-Disposition", "attachment; filename=\""+exporter.FileName+"\"")
+	return c.Blob(http.StatusOK, exporter.ContentType, buffer.Bytes())
 }
 
 // participantNew Form to create new participant
@@ -261,7 +623,9 @@ func participantNew(c echo.Context) error {
 
 	return c.Render(http.StatusOK, "templates/participant.twig", pongo2.Context{
 		"contest":     contest,
+		"fields":      contest.FormFields(),
 		"participant": nil,
+		"members":     teamMemberRows(contest, nil),
 	})
 }
 
@@ -279,7 +643,9 @@ func participantEdit(c echo.Context) error {
 
 	return c.Render(http.StatusOK, "templates/participant.twig", pongo2.Context{
 		"contest":     contest,
+		"fields":      contest.FormFields(),
 		"participant": participant,
+		"members":     teamMemberRows(contest, participant.Members),
 	})
 }
 
@@ -294,8 +660,12 @@ func participantSave(c echo.Context) error {
 	if err := (&echo.DefaultBinder{}).BindBody(c, &participantData); err != nil {
 		return err
 	}
-	if len(participantData.Name) == 0 {
-		return errors.New("participant name required")
+
+	answers, err := contestAnswers(requestLanguage(c), contest, func(key string) string {
+		return c.FormValue("answer_" + key)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
 	var participant *storage.ContestParticipant
@@ -308,27 +678,41 @@ func participantSave(c echo.Context) error {
 		if participant.ContestId != contest.Id {
 			return errors.New("participant does not belong to contest")
 		}
-		participant.Name = participantData.Name
-		participant.School = participantData.School
-		participant.Contacts = participantData.Contacts
-		participant.Languages = participantData.Languages
 		participant.Login = participantData.Login
 		participant.Password = participantData.Password
 	} else {
 		participant = &storage.ContestParticipant{
 			ContestId: contest.Id,
-			Name:      participantData.Name,
-			School:    participantData.School,
-			Contacts:  participantData.Contacts,
-			Languages: participantData.Languages,
 			Login:     participantData.Login,
 			Password:  participantData.Password,
 		}
 	}
+	participant.Room = strings.TrimSpace(participantData.Room)
+	participant.Seat = strings.TrimSpace(participantData.Seat)
+
+	if contest.TeamMode {
+		members, err := teamMembers(participant.Members, participantData.MemberChats, participantData.MemberNames)
+		if err != nil {
+			return err
+		}
+		if len(members) > contest.MaxMembers() {
+			return fmt.Errorf("team can have at most %d members", contest.MaxMembers())
+		}
+		participant.Members = members
+	}
+
+	for key, value := range answers {
+		participant.SetAnswer(key, value)
+	}
 
 	if err := storage.SaveContestParticipant(participant); err != nil {
 		return err
 	}
+	if participantData.Id != 0 {
+		webhooks.ParticipantUpdated(participant)
+	} else {
+		webhooks.ParticipantCreated(participant)
+	}
 
 	return c.Redirect(http.StatusFound, fmt.Sprintf("/contest/%d/participants", contest.Id))
 }
@@ -345,10 +729,119 @@ func participantDelete(c echo.Context) error {
 	if err := storage.DeleteContestParticipant(participant.Id); err != nil {
 		return err
 	}
+	webhooks.ParticipantDeleted(participant)
+	if err := promoteWaitlisted(contestId); err != nil {
+		return err
+	}
 
 	return c.Redirect(http.StatusFound, fmt.Sprintf("/contest/%d/participants", contestId))
 }
 
+// teamMemberRows Team members with empty rows for new members up to the team size
+func teamMemberRows(contest *storage.Contest, members []storage.TeamMember) []storage.TeamMember {
+	rows := append([]storage.TeamMember{}, members...)
+	for len(rows) < contest.MaxMembers() {
+		rows = append(rows, storage.TeamMember{})
+	}
+	return rows
+}
+
+// teamMembers Team members from the participant form, members are cleared to be removed.
+// Members joined in the bot while the form was open are kept
+func teamMembers(existing []storage.TeamMember, chats []int64, names []string) ([]storage.TeamMember, error) {
+	if len(chats) != len(names) {
+		return nil, errors.New("invalid team members data")
+	}
+
+	used := make([]bool, len(existing))
+	find := func(chatId int64, name string) int {
+		for i, member := range existing {
+			//members added by organizers are matched by name
+			if !used[i] && member.ChatId == chatId && (chatId != 0 || member.Name == name) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	posted := make(map[int64]bool)
+	var members []storage.TeamMember
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if chats[i] != 0 {
+			posted[chats[i]] = true
+		}
+		if len(name) == 0 {
+			continue
+		}
+
+		index := find(chats[i], name)
+		switch {
+		case index >= 0:
+			used[index] = true
+			member := existing[index]
+			member.Name = name
+			members = append(members, member)
+		case chats[i] == 0:
+			members = append(members, storage.TeamMember{Name: name, JoinedAt: time.Now()})
+		}
+	}
+
+	for _, member := range existing {
+		if member.ChatId != 0 && !posted[member.ChatId] {
+			members = append(members, member)
+		}
+	}
+
+	return members, nil
+}
+
+// contestAnswers Normalized answers to all contest form fields
+func contestAnswers(lang string, contest *storage.Contest, answer func(key string) string) (map[string]string, error) {
+	answers := make(map[string]string)
+	for _, field := range contest.FormFields() {
+		value, err := field.Normalize(answer(field.Key))
+		if err != nil {
+			return nil, errors.New(answerError(lang, &field, err))
+		}
+		answers[field.Key] = value
+	}
+	return answers, nil
+}
+
+// answerError Form field title and reason why the answer was rejected
+func answerError(lang string, field *storage.FormField, err error) string {
+	var validationErr *storage.ValidationError
+	if errors.As(err, &validationErr) {
+		return field.Title + ": " + i18n.T(lang, validationErr.Message, validationErr.Args...)
+	}
+	return field.Title + ": " + err.Error()
+}
+
+// promoteWaitlisted Register participants from the waitlist to free places and notify them
+func promoteWaitlisted(contestId uint64) error {
+	promoted, err := storage.PromoteWaitlistedParticipants(contestId)
+	if err != nil {
+		return err
+	}
+	for _, participant := range promoted {
+		webhooks.ParticipantUpdated(&participant)
+	}
+	if len(promoted) != 0 {
+		go registrationBot.NotifyPromoted(promoted)
+	}
+	return nil
+}
+
+// contestClosedEvent Notify webhooks about opened or closed registration
+func contestClosedEvent(contest *storage.Contest) {
+	if contest.Closed {
+		webhooks.ContestClosed(contest)
+	} else {
+		webhooks.ContestOpened(contest)
+	}
+}
+
 func contestParticipant(c echo.Context) (*storage.ContestParticipant, error) {
 	var id participantIdRequest
 	if err := (&echo.DefaultBinder{}).Bind(&id, c); err != nil {
@@ -381,9 +874,58 @@ func contestNotifications(c echo.Context) error {
 		return err
 	}
 
+	participants, err := storage.GetContestParticipants(contest.Id)
+	if err != nil {
+		return err
+	}
+	participantNames := make(map[uint64]string)
+	memberNames := make(map[int64]string)
+	for _, participant := range participants {
+		participantNames[participant.Id] = participant.Name
+		for _, member := range participant.Members {
+			if member.ChatId != 0 {
+				memberNames[member.ChatId] = participant.Name + " (" + member.Name + ")"
+			}
+		}
+	}
+
+	var views []notificationView
+	for _, notification := range notifications {
+		deliveries, err := storage.GetNotificationDeliveries(notification.Id)
+		if err != nil {
+			return err
+		}
+
+		view := notificationView{
+			Notification: notification,
+			Message:      bot.NotificationText(requestLanguage(c), contest, &notification),
+		}
+		for _, delivery := range deliveries {
+			switch delivery.Status {
+			case storage.DeliveryStatusPending:
+				view.Pending++
+			case storage.DeliveryStatusSent:
+				view.Sent++
+			case storage.DeliveryStatusFailed:
+				view.Failed++
+			case storage.DeliveryStatusBlocked:
+				view.Blocked++
+			}
+			name, ok := memberNames[delivery.ParticipantId]
+			if !ok {
+				name = participantNames[delivery.ContestParticipantId]
+			}
+			view.Deliveries = append(view.Deliveries, deliveryView{
+				Delivery:        delivery,
+				ParticipantName: name,
+			})
+		}
+		views = append(views, view)
+	}
+
 	return c.Render(http.StatusOK, "templates/notifications.twig", pongo2.Context{
 		"contest":       contest,
-		"notifications": notifications,
+		"notifications": views,
 	})
 }
 
@@ -415,6 +957,7 @@ func contestNotificationEdit(c echo.Context) error {
 	return c.Render(http.StatusOK, "templates/notification.twig", pongo2.Context{
 		"contest":      contest,
 		"notification": notification,
+		"message":      bot.NotificationText(requestLanguage(c), contest, notification),
 	})
 }
 
@@ -432,6 +975,10 @@ func contestNotificationSave(c echo.Context) error {
 	if len(notificationData.Message) == 0 {
 		return errors.New("notification message required")
 	}
+	sendAt, err := parseDateTime(notificationData.SendAt, contest.Location())
+	if err != nil {
+		return fmt.Errorf("notification sending time: %s", err)
+	}
 
 	var notification *storage.ContestNotification
 
@@ -443,7 +990,10 @@ func contestNotificationSave(c echo.Context) error {
 		if notification.ContestId != contest.Id {
 			return errors.New("notification belongs to other contest")
 		}
-		notification.Message = notificationData.Message
+		//unchanged reminder text keeps its message key to stay in the language of each participant
+		if notificationData.Message != bot.NotificationText(requestLanguage(c), contest, notification) {
+			notification.Message = notificationData.Message
+		}
 	} else {
 		notification = &storage.ContestNotification{
 			ContestId: contest.Id,
@@ -451,10 +1001,7 @@ func contestNotificationSave(c echo.Context) error {
 		}
 	}
 
-	if err := storage.SaveContestNotification(notification); err != nil {
-		return err
-	}
-	if err := registrationBot.SendNotifications(contest.Id, notification.Message); err != nil {
+	if err := scheduleNotification(notification, sendAt); err != nil {
 		return err
 	}
 
@@ -473,6 +1020,9 @@ func contestNotificationDelete(c echo.Context) error {
 		return err
 	}
 
+	if err := storage.DeleteNotificationDeliveries(notification.Id); err != nil {
+		return err
+	}
 	if err := storage.DeleteContestNotification(notification.Id); err != nil {
 		return err
 	}
@@ -480,6 +1030,26 @@ func contestNotificationDelete(c echo.Context) error {
 	return c.Redirect(http.StatusFound, fmt.Sprintf("/contest/%d/notifications", contest.Id))
 }
 
+// scheduleNotification Save notification to be sent at given time,
+// send it right away when the time is empty or not in the future
+func scheduleNotification(notification *storage.ContestNotification, sendAt time.Time) error {
+	now := time.Now()
+	notification.SendAt = sendAt
+	notification.SentAt = time.Time{}
+	sendNow := sendAt.IsZero() || !sendAt.After(now)
+	if sendNow {
+		notification.SentAt = now
+	}
+
+	if err := storage.SaveContestNotification(notification); err != nil {
+		return err
+	}
+	if sendNow {
+		return registrationBot.SendNotifications(notification)
+	}
+	return nil
+}
+
 func contestNotification(c echo.Context) (*storage.ContestNotification, error) {
 	var notificationId notificationIdRequest
 	if err := (&echo.DefaultBinder{}).Bind(&notificationId, c); err != nil {
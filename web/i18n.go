@@ -0,0 +1,77 @@
+package web
+
+import (
+	"contest-registration-bot/i18n"
+	"github.com/flosch/pongo2/v4"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	languageCookieName     = "lang"
+	languageCookieLifetime = 365 * 24 * time.Hour
+)
+
+type languageRequest struct {
+	Language string `param:"lang"`
+}
+
+func init() {
+	//{{ "web.key"|t:lang }}
+	pongo2.RegisterFilter("t", func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return pongo2.AsValue(i18n.T(param.String(), in.String())), nil
+	})
+}
+
+// requestLanguage Language chosen in admin panel or taken from browser settings
+func requestLanguage(c echo.Context) string {
+	if cookie, err := c.Cookie(languageCookieName); err == nil && i18n.Supported(cookie.Value) {
+		return cookie.Value
+	}
+	return i18n.Language(c.Request().Header.Get("Accept-Language"))
+}
+
+// t Message in the request language
+func t(c echo.Context, key string, args ...interface{}) string {
+	return i18n.T(requestLanguage(c), key, args...)
+}
+
+// languageGet Remember admin panel language and return to the previous page
+func languageGet(c echo.Context) error {
+	var request languageRequest
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, &request); err != nil {
+		return err
+	}
+	if !i18n.Supported(request.Language) {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     languageCookieName,
+		Value:    request.Language,
+		Path:     "/",
+		Expires:  time.Now().Add(languageCookieLifetime),
+		HttpOnly: true,
+		Secure:   configuration.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return c.Redirect(http.StatusFound, sameSiteRedirect(c.Request().Referer(), c.Request().Host))
+}
+
+// sameSiteRedirect Path of the referring page of this site, "/" for other sites
+// and paths browsers would read as links to other hosts
+func sameSiteRedirect(referer, host string) string {
+	refererURL, err := url.Parse(referer)
+	if err != nil || refererURL.Host != host {
+		return "/"
+	}
+	redirect := refererURL.RequestURI()
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
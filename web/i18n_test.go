@@ -0,0 +1,32 @@
+package web
+
+import "testing"
+
+func TestSameSiteRedirect(t *testing.T) {
+	const host = "admin.example.org"
+	tests := []struct {
+		referer string
+		want    string
+	}{
+		{"https://admin.example.org/contest/1/participants?sort=name", "/contest/1/participants?sort=name"},
+		{"http://admin.example.org/", "/"},
+		{"https://admin.example.org", "/"},
+		{"https://evil.example/contest/1", "/"},
+		{"https://admin.example.org.evil.example/contest/1", "/"},
+		{"https://evil//evil.example/x", "/"},
+		{"https://admin.example.org//evil.example/x", "/"},
+		{"https://admin.example.org/\\evil.example/x", "/%5Cevil.example/x"},
+		{"/contest/1", "/"},
+		{"//evil.example/x", "/"},
+		{"", "/"},
+		{"%zz", "/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.referer, func(t *testing.T) {
+			if got := sameSiteRedirect(test.referer, host); got != test.want {
+				t.Errorf("sameSiteRedirect(%q) = %q, want %q", test.referer, got, test.want)
+			}
+		})
+	}
+}
@@ -6,50 +6,98 @@ import (
 	"github.com/labstack/echo/v4"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"time"
 )
 
+const defaultSessionLifetime = 7 * 24 * time.Hour
+
 type Configuration struct {
-	DebugTemplates bool
-	Listen         string
+	DebugTemplates  bool
+	Listen          string
+	SessionLifetime time.Duration
+	SecureCookies   bool
 }
 
 var (
+	configuration   Configuration
 	registrationBot *bot.Bot
 )
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func NewServer(configuration Configuration, b *bot.Bot) *echo.Echo {
+func NewServer(config Configuration, b *bot.Bot) *echo.Echo {
+	if config.SessionLifetime == 0 {
+		config.SessionLifetime = defaultSessionLifetime
+	}
+	configuration = config
 	registrationBot = b
 
 	e := echo.New()
 
 	e.HideBanner = true
-	e.Renderer = Pongo2Renderer{Debug: configuration.DebugTemplates}
+	e.Renderer = Pongo2Renderer{Debug: config.DebugTemplates}
 	e.HTTPErrorHandler = httpErrorHandler
 	e.Static("/assets", "assets")
 
-	e.GET("/", contestsGet)
-	e.GET("/contest", contestNew)
-	e.GET("/contest/:id", contestGet)
-	e.POST("/contest", contestSave)
-	e.POST("/contest/:id/hide", contestHide)
-	e.POST("/contest/:id/show", contestShow)
-	e.POST("/contest/:id/close", contestClose)
-	e.POST("/contest/:id/open", contestOpen)
-
-	e.GET("/contest/:id/participants", participantsList)
-	e.GET("/contest/:id/participants/export", participantsExport)
-	e.GET("/contest/:id/participant", participantNew)
-	e.GET("/contest/:id/participant/:participant_id", participantEdit)
-	e.POST("/contest/:id/participant", participantSave)
-	e.POST("/contest/:id/participant/:participant_id/delete", participantDelete)
-
-	e.GET("/contest/:id/notifications", contestNotifications)
-	e.GET("/contest/:id/notification", contestNotificationNew)
-	e.GET("/contest/:id/notification/:notification_id", contestNotificationEdit)
-	e.POST("/contest/:id/notification", contestNotificationSave)
-	e.POST("/contest/:id/notification/:notification_id/delete", contestNotificationDelete)
+	if b.WebhookEnabled() {
+		e.POST(b.WebhookPath(), echo.WrapHandler(b.WebhookHandler()))
+	}
+
+	e.GET("/login", loginGet)
+	e.POST("/login", loginPost)
+	e.GET("/language/:lang", languageGet)
+
+	admin := e.Group("", authenticate, verifyCSRF)
+	admin.POST("/logout", logout)
+	admin.GET("/", contestsGet)
+	admin.GET("/contest/:id/participants", participantsList)
+	admin.GET("/tokens", tokensGet)
+	admin.POST("/tokens", tokenCreate)
+	admin.POST("/tokens/:token_id/delete", tokenDelete)
+
+	organizer := admin.Group("", requireOrganizer)
+	organizer.GET("/contest", contestNew)
+	organizer.GET("/contest/:id", contestGet)
+	organizer.GET("/contest/:id/link.png", contestLinkQR)
+	organizer.POST("/contest", contestSave)
+	organizer.POST("/contest/:id/hide", contestHide)
+	organizer.POST("/contest/:id/show", contestShow)
+	organizer.POST("/contest/:id/close", contestClose)
+	organizer.POST("/contest/:id/open", contestOpen)
+
+	organizer.GET("/contest/:id/participants/export", participantsExport)
+	organizer.GET("/contest/:id/participants/print", participantsPrint)
+	organizer.POST("/contest/:id/participants/import", participantsImportUpload)
+	organizer.GET("/contest/:id/participants/import/:import_id", participantsImportGet)
+	organizer.POST("/contest/:id/participants/import/:import_id", participantsImportConfirm)
+	organizer.POST("/contest/:id/participants/import/:import_id/mapping", participantsImportMapping)
+	organizer.POST("/contest/:id/participants/import/:import_id/delete", participantsImportCancel)
+	organizer.GET("/contest/:id/participant", participantNew)
+	organizer.GET("/contest/:id/participant/:participant_id", participantEdit)
+	organizer.POST("/contest/:id/participant", participantSave)
+	organizer.POST("/contest/:id/participant/:participant_id/delete", participantDelete)
+
+	organizer.GET("/contest/:id/notifications", contestNotifications)
+	organizer.GET("/contest/:id/notification", contestNotificationNew)
+	organizer.GET("/contest/:id/notification/:notification_id", contestNotificationEdit)
+	organizer.POST("/contest/:id/notification", contestNotificationSave)
+	organizer.POST("/contest/:id/notification/:notification_id/delete", contestNotificationDelete)
+
+	organizer.GET("/webhooks", webhooksGet)
+	organizer.GET("/webhook", webhookNew)
+	organizer.GET("/webhook/:webhook_id", webhookEdit)
+	organizer.POST("/webhook", webhookSave)
+	organizer.POST("/webhook/:webhook_id/delete", webhookDelete)
+	organizer.POST("/webhook/:webhook_id/ping", webhookPing)
+	organizer.POST("/webhook/:webhook_id/delivery/:delivery_id/retry", webhookDeliveryRetry)
+
+	organizer.GET("/data", dataGet)
+	organizer.GET("/data/backup", backupGet)
+	organizer.GET("/data/export", exportGet)
+	organizer.POST("/data/import", importPost)
+	organizer.POST("/data/forget", forgetPost)
+
+	registerAPI(e)
 
 	return e
 }
@@ -65,6 +113,11 @@ func httpErrorHandler(e error, c echo.Context) {
 
 	log.Errorf("http error: %s, method=%s, url=%s", e, c.Request().Method, c.Request().URL)
 
+	if isAPIRequest(c) {
+		apiErrorHandler(e, c)
+		return
+	}
+
 	err := c.Render(code, "templates/error.twig", pongo2.Context{
 		"error": e,
 	})
@@ -0,0 +1,425 @@
+package web
+
+import (
+	"contest-registration-bot/i18n"
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	"fmt"
+	"github.com/flosch/pongo2/v4"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxImportFileSize = 5 << 20
+	importLifetime    = 24 * time.Hour
+)
+
+// importColumn Choice of table column mapping
+type importColumn struct {
+	Key   string
+	Title string
+}
+
+// importRow Preview of one table row mapped to participant
+type importRow struct {
+	Number    int
+	Values    []string
+	Errors    []string
+	Duplicate string
+	// Participant to create, nil when row has errors or is a duplicate
+	Participant *storage.ContestParticipant
+}
+
+// importMappingColumn Table column with its current mapping
+type importMappingColumn struct {
+	Number  int
+	Header  string
+	Key     string
+	Samples []string
+}
+
+type importMappingRequest struct {
+	Columns   []string `form:"column"`
+	HasHeader string   `form:"has_header"`
+}
+
+// participantsImportUpload Read uploaded table and show column mapping
+func participantsImportUpload(c echo.Context) error {
+	contest, err := contest(c)
+	if err != nil {
+		return err
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return errors.New("participants table file required")
+	}
+	if fileHeader.Size > maxImportFileSize {
+		return fmt.Errorf("file is too large, at most %d MB allowed", maxImportFileSize>>20)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImportFileSize))
+	if err != nil {
+		return err
+	}
+	rows, err := readTable(fileHeader.Filename, data)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", fileHeader.Filename, err)
+	}
+
+	if err := storage.DeleteExpiredParticipantImports(time.Now().Add(-importLifetime)); err != nil {
+		return err
+	}
+
+	participantImport := &storage.ParticipantImport{
+		ContestId: contest.Id,
+		FileName:  fileHeader.Filename,
+		Rows:      rows,
+		CreatedAt: time.Now(),
+	}
+	participantImport.Columns, participantImport.HasHeader = guessImportColumns(contest, rows[0])
+
+	if err := storage.SaveParticipantImport(participantImport); err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, fmt.Sprintf("/contest/%d/participants/import/%d", contest.Id, participantImport.Id))
+}
+
+// participantsImportGet Column mapping and preview of uploaded table
+func participantsImportGet(c echo.Context) error {
+	contest, participantImport, err := participantImport(c)
+	if err != nil {
+		return err
+	}
+
+	lang := requestLanguage(c)
+	rows, err := importPreview(lang, contest, participantImport)
+	if err != nil {
+		return err
+	}
+
+	valid, invalid, duplicates := 0, 0, 0
+	for _, row := range rows {
+		switch {
+		case row.Participant != nil:
+			valid++
+		case len(row.Duplicate) != 0:
+			duplicates++
+		default:
+			invalid++
+		}
+	}
+
+	return c.Render(http.StatusOK, "templates/participants_import.twig", pongo2.Context{
+		"contest":         contest,
+		"import":          participantImport,
+		"columns":         importMappingColumns(participantImport),
+		"column_choices":  importColumnChoices(lang, contest),
+		"preview_columns": importPreviewColumns(lang, contest, participantImport),
+		"rows":            rows,
+		"valid":           valid,
+		"invalid":         invalid,
+		"duplicates":      duplicates,
+	})
+}
+
+// participantsImportMapping Save column mapping of uploaded table
+func participantsImportMapping(c echo.Context) error {
+	contest, participantImport, err := participantImport(c)
+	if err != nil {
+		return err
+	}
+
+	var mappingData importMappingRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &mappingData); err != nil {
+		return err
+	}
+
+	choices := make(map[string]bool)
+	for _, choice := range importColumnChoices(requestLanguage(c), contest) {
+		choices[choice.Key] = true
+	}
+	used := make(map[string]bool)
+
+	columns := make([]string, len(participantImport.Rows[0]))
+	for i := range columns {
+		if i >= len(mappingData.Columns) || len(mappingData.Columns[i]) == 0 {
+			continue
+		}
+		key := mappingData.Columns[i]
+		if !choices[key] {
+			return fmt.Errorf("unknown participant field: %s", key)
+		}
+		if used[key] {
+			return fmt.Errorf("participant field %s is mapped to several columns", key)
+		}
+		used[key] = true
+		columns[i] = key
+	}
+
+	participantImport.Columns = columns
+	participantImport.HasHeader = mappingData.HasHeader == "1"
+	if participantImport.HasHeader && len(participantImport.Rows) < 2 {
+		return errors.New("table has no rows except header")
+	}
+
+	if err := storage.SaveParticipantImport(participantImport); err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, fmt.Sprintf("/contest/%d/participants/import/%d", contest.Id, participantImport.Id))
+}
+
+// participantsImportConfirm Create participants from valid rows of uploaded table
+func participantsImportConfirm(c echo.Context) error {
+	contest, participantImport, err := participantImport(c)
+	if err != nil {
+		return err
+	}
+
+	rows, err := importPreview(requestLanguage(c), contest, participantImport)
+	if err != nil {
+		return err
+	}
+
+	var participants []*storage.ContestParticipant
+	for _, row := range rows {
+		if row.Participant != nil {
+			participants = append(participants, row.Participant)
+		}
+	}
+	if len(participants) == 0 {
+		return errors.New("no valid rows to import")
+	}
+
+	if err := storage.ImportContestParticipants(participantImport.Id, participants); err != nil {
+		return fmt.Errorf("import failed, no participants created: %s", err)
+	}
+	for _, participant := range participants {
+		webhooks.ParticipantCreated(participant)
+	}
+
+	return c.Redirect(http.StatusFound, fmt.Sprintf("/contest/%d/participants", contest.Id))
+}
+
+// participantsImportCancel Delete uploaded table
+func participantsImportCancel(c echo.Context) error {
+	contest, participantImport, err := participantImport(c)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.DeleteParticipantImport(participantImport.Id); err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, fmt.Sprintf("/contest/%d/participants", contest.Id))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func participantImport(c echo.Context) (*storage.Contest, *storage.ParticipantImport, error) {
+	contest, err := contest(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	importId, err := strconv.ParseUint(c.Param("import_id"), 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	participantImport, err := storage.GetParticipantImport(importId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if participantImport.ContestId != contest.Id {
+		return nil, nil, errors.New("import belongs to other contest")
+	}
+
+	return contest, participantImport, nil
+}
+
+// importColumnChoices Participant fields table columns can be mapped to
+func importColumnChoices(lang string, contest *storage.Contest) []importColumn {
+	var choices []importColumn
+	for _, field := range contest.FormFields() {
+		choices = append(choices, importColumn{Key: field.Key, Title: field.Title})
+	}
+	choices = append(choices,
+		importColumn{Key: storage.ImportColumnLogin, Title: i18n.T(lang, "web.common.login")},
+		importColumn{Key: storage.ImportColumnPassword, Title: i18n.T(lang, "web.common.password")},
+		importColumn{Key: storage.ImportColumnRoom, Title: i18n.T(lang, "web.common.room")},
+		importColumn{Key: storage.ImportColumnSeat, Title: i18n.T(lang, "web.common.seat")},
+	)
+	return choices
+}
+
+// importMappingColumns Table columns with header and first values
+func importMappingColumns(participantImport *storage.ParticipantImport) []importMappingColumn {
+	const samplesCount = 3
+
+	tableRows := participantImport.Rows
+	if participantImport.HasHeader {
+		tableRows = tableRows[1:]
+	}
+	tableRows = tableRows[:min(len(tableRows), samplesCount)]
+
+	var columns []importMappingColumn
+	for i, key := range participantImport.Columns {
+		column := importMappingColumn{Number: i + 1, Key: key}
+		if participantImport.HasHeader {
+			column.Header = participantImport.Rows[0][i]
+		}
+		for _, row := range tableRows {
+			column.Samples = append(column.Samples, row[i])
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// importPreviewColumns Titles of mapped participant fields in order of choices
+func importPreviewColumns(lang string, contest *storage.Contest, participantImport *storage.ParticipantImport) []importColumn {
+	var columns []importColumn
+	for _, choice := range importColumnChoices(lang, contest) {
+		for _, key := range participantImport.Columns {
+			if key == choice.Key {
+				columns = append(columns, choice)
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// guessImportColumns Map columns by header titles, first column is participant's name when there is no header
+func guessImportColumns(contest *storage.Contest, firstRow []string) ([]string, bool) {
+	columns := make([]string, len(firstRow))
+
+	//headers are matched in all languages
+	names := make(map[string]string)
+	for _, lang := range i18n.Languages() {
+		for _, choice := range importColumnChoices(lang, contest) {
+			names[strings.ToLower(choice.Key)] = choice.Key
+			names[strings.ToLower(choice.Title)] = choice.Key
+		}
+	}
+	names["login"] = storage.ImportColumnLogin
+	names["password"] = storage.ImportColumnPassword
+	names["room"] = storage.ImportColumnRoom
+	names["seat"] = storage.ImportColumnSeat
+
+	hasHeader := false
+	used := make(map[string]bool)
+	for i, cell := range firstRow {
+		key, ok := names[strings.ToLower(strings.TrimSpace(cell))]
+		if !ok || used[key] {
+			continue
+		}
+		columns[i] = key
+		used[key] = true
+		hasHeader = true
+	}
+
+	if !hasHeader && len(columns) != 0 {
+		columns[0] = storage.FormFieldName
+	}
+
+	return columns, hasHeader
+}
+
+// importPreview Validate mapped table rows and detect duplicates
+// of existing contest participants and of previous rows
+func importPreview(lang string, contest *storage.Contest, participantImport *storage.ParticipantImport) ([]importRow, error) {
+	existing, err := storage.GetContestParticipants(contest.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	logins := make(map[string]string)
+	for _, participant := range existing {
+		names[duplicateKey(participant.Name)] = i18n.T(lang, "web.import.registered", participant.Name)
+		if len(participant.Login) != 0 {
+			logins[participant.Login] = i18n.T(lang, "web.import.login_taken", participant.Login)
+		}
+	}
+
+	columns := importPreviewColumns(lang, contest, participantImport)
+	tableRows := participantImport.Rows
+	firstRowNumber := 1
+	if participantImport.HasHeader {
+		tableRows = tableRows[1:]
+		firstRowNumber = 2
+	}
+
+	var rows []importRow
+
+	for index, tableRow := range tableRows {
+		row := importRow{Number: firstRowNumber + index}
+
+		values := make(map[string]string)
+		for column, key := range participantImport.Columns {
+			if len(key) != 0 && column < len(tableRow) {
+				values[key] = tableRow[column]
+			}
+		}
+		for _, column := range columns {
+			row.Values = append(row.Values, values[column.Key])
+		}
+
+		participant := &storage.ContestParticipant{
+			ContestId: contest.Id,
+			Login:     values[storage.ImportColumnLogin],
+			Password:  values[storage.ImportColumnPassword],
+			Room:      values[storage.ImportColumnRoom],
+			Seat:      values[storage.ImportColumnSeat],
+		}
+		for _, field := range contest.FormFields() {
+			value, err := field.Normalize(values[field.Key])
+			if err != nil {
+				row.Errors = append(row.Errors, answerError(lang, &field, err))
+				continue
+			}
+			participant.SetAnswer(field.Key, value)
+		}
+		if strings.ContainsAny(participant.Login, " \t;,") {
+			row.Errors = append(row.Errors, i18n.T(lang, "web.import.login_invalid"))
+		}
+
+		if len(row.Errors) == 0 {
+			if duplicate, ok := names[duplicateKey(participant.Name)]; ok {
+				row.Duplicate = duplicate
+			} else if duplicate, ok := logins[participant.Login]; ok && len(participant.Login) != 0 {
+				row.Duplicate = duplicate
+			} else {
+				row.Participant = participant
+				names[duplicateKey(participant.Name)] = i18n.T(lang, "web.import.duplicate_row", row.Number)
+				if len(participant.Login) != 0 {
+					logins[participant.Login] = i18n.T(lang, "web.import.duplicate_login", row.Number)
+				}
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// duplicateKey Participant's name compared case and space insensitive
+func duplicateKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"contest-registration-bot/storage"
+	"encoding/base64"
+	"fmt"
+	"github.com/flosch/pongo2/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/skip2/go-qrcode"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	qrCodeSize = 256
+	// contestLinkQRSize Size of contest link QR code, large enough for posters
+	contestLinkQRSize = 1024
+)
+
+// printLayout Page layout of printed participant slips
+type printLayout struct {
+	Key string
+	// Title Message key of layout title
+	Title   string
+	Columns int
+	// PerPage Allowed numbers of slips per page
+	PerPage        []int
+	DefaultPerPage int
+	Badge          bool
+}
+
+// printSlip Participant data printed on one slip
+type printSlip struct {
+	exportParticipant
+	Room string
+	Seat string
+	QR   string
+}
+
+// printOrder Order of printed slips
+type printOrder struct {
+	Key string
+	// Title Message key of order title
+	Title string
+}
+
+var printLayouts = []printLayout{
+	{Key: "slips", Title: "web.print.layout_slips", Columns: 2, PerPage: []int{4, 6, 8, 10, 12}, DefaultPerPage: 8},
+	{Key: "badges", Title: "web.print.layout_badges", Columns: 2, PerPage: []int{10}, DefaultPerPage: 10, Badge: true},
+}
+
+var printOrders = []printOrder{
+	{Key: "registration", Title: "web.print.order_registration"},
+	{Key: "name", Title: "web.print.order_name"},
+	{Key: "room", Title: "web.print.order_room"},
+}
+
+// participantsPrint Print-optimized page with one cut-out slip per registered participant
+func participantsPrint(c echo.Context) error {
+	contest, err := contest(c)
+	if err != nil {
+		return err
+	}
+
+	layout := printLayouts[0]
+	for _, l := range printLayouts {
+		if l.Key == c.QueryParam("layout") {
+			layout = l
+		}
+	}
+
+	perPage := layout.DefaultPerPage
+	if value, err := strconv.Atoi(c.QueryParam("per_page")); err == nil {
+		for _, allowed := range layout.PerPage {
+			if value == allowed {
+				perPage = value
+			}
+		}
+	}
+
+	order := c.QueryParam("order")
+	withQR := c.QueryParam("qr") == "1"
+
+	participants, err := storage.GetContestParticipants(contest.Id)
+	if err != nil {
+		return err
+	}
+
+	var slips []printSlip
+	for _, participant := range exportParticipants(contest, participants) {
+		slip := printSlip{
+			exportParticipant: participant,
+			Room:              participant.Participant.Room,
+			Seat:              participant.Participant.Seat,
+		}
+		if withQR {
+			png, err := qrcode.Encode(strconv.FormatUint(participant.Participant.Id, 10), qrcode.Medium, qrCodeSize)
+			if err != nil {
+				return fmt.Errorf("unable to generate QR code: %s", err)
+			}
+			slip.QR = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+		}
+		slips = append(slips, slip)
+	}
+	sortSlips(slips, order)
+
+	var pages [][]printSlip
+	for start := 0; start < len(slips); start += perPage {
+		pages = append(pages, slips[start:min(start+perPage, len(slips))])
+	}
+
+	return c.Render(http.StatusOK, "templates/participants_print.twig", pongo2.Context{
+		"contest":  contest,
+		"layouts":  printLayouts,
+		"layout":   layout,
+		"per_page": perPage,
+		"rows":     perPage / layout.Columns,
+		"orders":   printOrders,
+		"order":    order,
+		"qr":       withQR,
+		"pages":    pages,
+		"count":    len(slips),
+	})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// sortSlips Order slips for cutting and handing out
+func sortSlips(slips []printSlip, order string) {
+	switch order {
+	case "name":
+		sort.SliceStable(slips, func(i, j int) bool {
+			return strings.ToLower(slips[i].Name) < strings.ToLower(slips[j].Name)
+		})
+	case "room":
+		sort.SliceStable(slips, func(i, j int) bool {
+			if slips[i].Room != slips[j].Room {
+				//participants without room are printed last
+				if len(slips[i].Room) == 0 || len(slips[j].Room) == 0 {
+					return len(slips[j].Room) == 0
+				}
+				return naturalLess(slips[i].Room, slips[j].Room)
+			}
+			return naturalLess(slips[i].Seat, slips[j].Seat)
+		})
+	}
+}
+
+// naturalLess Compare numbers by value, other strings alphabetically
+func naturalLess(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
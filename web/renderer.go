@@ -3,6 +3,7 @@ package web
 //from https://github.com/stnc/pongo4echo
 
 import (
+	"contest-registration-bot/i18n"
 	"errors"
 	"github.com/flosch/pongo2/v4"
 	"github.com/labstack/echo/v4"
@@ -23,6 +24,17 @@ func (r Pongo2Renderer) Render(w io.Writer, name string, data interface{}, c ech
 		}
 	}
 
+	if ctx != nil {
+		ctx["lang"] = requestLanguage(c)
+		ctx["languages"] = i18n.Languages()
+		if user := currentUser(c); user != nil {
+			ctx["current_user"] = user
+		}
+		if session := currentSession(c); session != nil {
+			ctx["csrf_token"] = session.CSRFToken
+		}
+	}
+
 	var t *pongo2.Template
 	var err error
 	if r.Debug {
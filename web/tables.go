@@ -0,0 +1,309 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"golang.org/x/text/encoding/charmap"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTableRows    = 5000
+	maxTableColumns = 50
+)
+
+var errTableEmpty = errors.New("table is empty")
+
+// readTable Read rows of uploaded CSV or XLSX table
+func readTable(fileName string, data []byte) ([][]string, error) {
+	var rows [][]string
+	var err error
+
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".xlsx":
+		rows, err = readXLSX(data)
+	case ".csv", ".txt", ".tsv":
+		rows, err = readCSV(data)
+	default:
+		return nil, errors.New("unsupported file type, CSV or XLSX expected")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeTable(rows)
+}
+
+// normalizeTable Trim cells, drop empty rows and pad rows to the same width
+func normalizeTable(rows [][]string) ([][]string, error) {
+	var table [][]string
+	width := 0
+
+	for _, row := range rows {
+		empty := true
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+			if len(row[i]) != 0 {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+		for len(row) > 0 && len(row[len(row)-1]) == 0 {
+			row = row[:len(row)-1]
+		}
+		width = max(width, len(row))
+		table = append(table, row)
+	}
+
+	if len(table) == 0 {
+		return nil, errTableEmpty
+	}
+	if len(table) > maxTableRows {
+		return nil, fmt.Errorf("too many rows, at most %d allowed", maxTableRows)
+	}
+	if width > maxTableColumns {
+		return nil, fmt.Errorf("too many columns, at most %d allowed", maxTableColumns)
+	}
+
+	for i, row := range table {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		table[i] = row
+	}
+
+	return table, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// readCSV Read CSV in UTF-8 or CP1251 with semicolon, comma or tab delimiter
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %s", err)
+	}
+	return rows, nil
+}
+
+// detectDelimiter Most frequent delimiter in the first line outside of quotes
+func detectDelimiter(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+
+	counts := map[rune]int{}
+	quoted := false
+	for _, r := range string(line) {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ';' || r == ',' || r == '\t'):
+			counts[r]++
+		}
+	}
+
+	delimiter := ','
+	for _, candidate := range []rune{';', '\t'} {
+		if counts[candidate] > counts[delimiter] {
+			delimiter = candidate
+		}
+	}
+	return delimiter
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelationId string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (text xlsxText) String() string {
+	if len(text.Runs) == 0 {
+		return text.Text
+	}
+	str := strings.Builder{}
+	for _, run := range text.Runs {
+		str.WriteString(run.Text)
+	}
+	return str.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Reference string   `xml:"r,attr"`
+			Type      string   `xml:"t,attr"`
+			Value     string   `xml:"v"`
+			Inline    xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX Read cell values of the first worksheet
+func readXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX: %s", err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXLSXPart(file, &sharedStrings); err != nil {
+			return nil, err
+		}
+	}
+
+	file, ok := files[xlsxFirstSheet(files)]
+	if !ok {
+		return nil, errors.New("invalid XLSX: worksheet not found")
+	}
+	var worksheet xlsxWorksheet
+	if err := readXLSXPart(file, &worksheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+
+	for _, sheetRow := range worksheet.Rows {
+		var row []string
+		for _, cell := range sheetRow.Cells {
+			column := len(row)
+			if len(cell.Reference) != 0 {
+				if referenced := xlsxColumn(cell.Reference); referenced >= 0 {
+					column = referenced
+				}
+			}
+			if column >= maxTableColumns {
+				return nil, fmt.Errorf("too many columns, at most %d allowed", maxTableColumns)
+			}
+			for len(row) <= column {
+				row = append(row, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				var index int
+				if _, err := fmt.Sscan(cell.Value, &index); err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, errors.New("invalid XLSX: shared string not found")
+				}
+				row[column] = sharedStrings.Items[index].String()
+			case "inlineStr":
+				row[column] = cell.Inline.String()
+			case "b":
+				if cell.Value == "1" {
+					row[column] = "TRUE"
+				} else {
+					row[column] = "FALSE"
+				}
+			default:
+				row[column] = cell.Value
+			}
+		}
+		rows = append(rows, row)
+		if len(rows) > maxTableRows {
+			return nil, fmt.Errorf("too many rows, at most %d allowed", maxTableRows)
+		}
+	}
+
+	return rows, nil
+}
+
+// xlsxFirstSheet Path of the first worksheet in the archive
+func xlsxFirstSheet(files map[string]*zip.File) string {
+	const defaultSheet = "xl/worksheets/sheet1.xml"
+
+	var workbook xlsxWorkbook
+	var relationships xlsxRelationships
+	workbookFile, ok := files["xl/workbook.xml"]
+	if !ok || readXLSXPart(workbookFile, &workbook) != nil || len(workbook.Sheets) == 0 {
+		return defaultSheet
+	}
+	relationshipsFile, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok || readXLSXPart(relationshipsFile, &relationships) != nil {
+		return defaultSheet
+	}
+
+	for _, relationship := range relationships.Relationships {
+		if relationship.Id != workbook.Sheets[0].RelationId {
+			continue
+		}
+		if strings.HasPrefix(relationship.Target, "/") {
+			return strings.TrimPrefix(relationship.Target, "/")
+		}
+		return path.Join("xl", relationship.Target)
+	}
+
+	return defaultSheet
+}
+
+func readXLSXPart(file *zip.File, value interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(reader, 64<<20)).Decode(value); err != nil {
+		return fmt.Errorf("invalid XLSX part %s: %s", file.Name, err)
+	}
+	return nil
+}
+
+// xlsxColumn Zero-based column index of cell reference like "AB12"
+func xlsxColumn(reference string) int {
+	column := 0
+	for _, r := range reference {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+	}
+	return column - 1
+}
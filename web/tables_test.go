@@ -0,0 +1,169 @@
+package web
+
+import (
+	"errors"
+	"golang.org/x/text/encoding/charmap"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTable(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]string
+		want [][]string
+		err  error
+	}{
+		{
+			name: "cells are trimmed and rows padded",
+			rows: [][]string{{" name ", "school", "grade"}, {"Ivan Petrov", " 1 "}},
+			want: [][]string{{"name", "school", "grade"}, {"Ivan Petrov", "1", ""}},
+		},
+		{
+			name: "empty rows are dropped",
+			rows: [][]string{{"", " "}, {"a", "b"}, {}, {"\t", ""}, {"c", "d"}},
+			want: [][]string{{"a", "b"}, {"c", "d"}},
+		},
+		{
+			name: "trailing empty columns are dropped",
+			rows: [][]string{{"a", "", ""}, {"b", "c", " "}},
+			want: [][]string{{"a", ""}, {"b", "c"}},
+		},
+		{
+			name: "inner empty cells are kept",
+			rows: [][]string{{"a", "", "c"}},
+			want: [][]string{{"a", "", "c"}},
+		},
+		{
+			name: "only empty rows",
+			rows: [][]string{{""}, {" ", "\t"}},
+			err:  errTableEmpty,
+		},
+		{
+			name: "no rows",
+			err:  errTableEmpty,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := normalizeTable(test.rows)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("normalizeTable() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTableLimits(t *testing.T) {
+	rows := make([][]string, maxTableRows+1)
+	for i := range rows {
+		rows[i] = []string{"a"}
+	}
+	if _, err := normalizeTable(rows); err == nil {
+		t.Error("too many rows accepted")
+	}
+
+	if _, err := normalizeTable([][]string{make([]string, maxTableColumns+1)}); !errors.Is(err, errTableEmpty) {
+		t.Errorf("row of empty cells: error = %v, want %v", err, errTableEmpty)
+	}
+
+	wide := make([]string, maxTableColumns+1)
+	wide[maxTableColumns] = "a"
+	if _, err := normalizeTable([][]string{wide}); err == nil {
+		t.Error("too many columns accepted")
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want rune
+	}{
+		{"semicolon", "name;school;grade\nIvan;1;5", ';'},
+		{"comma", "name,school,grade\nIvan,1,5", ','},
+		{"tab", "name\tschool\tgrade\nIvan\t1\t5", '\t'},
+		{"delimiters in quotes are skipped", "\"Petrov, Ivan\";\"School, 1\"\n", ';'},
+		{"only first line is used", "name;school\na,b,c,d,e", ';'},
+		{"comma by default", "name\nIvan", ','},
+		{"comma wins a tie", "a;b,c\n", ','},
+		{"empty", "", ','},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := detectDelimiter([]byte(test.data)); got != test.want {
+				t.Errorf("detectDelimiter() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	cp1251, err := charmap.Windows1251.NewEncoder().String("ФИО;Школа\nИванов Иван;Лицей 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"ФИО", "Школа"}, {"Иванов Иван", "Лицей 1"}}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"utf-8", "ФИО;Школа\nИванов Иван;Лицей 1\n"},
+		{"utf-8 with BOM", "\xEF\xBB\xBFФИО;Школа\nИванов Иван;Лицей 1\n"},
+		{"windows-1251", cp1251},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readCSV([]byte(test.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("readCSV() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      int
+	}{
+		{"A1", 0},
+		{"B12", 1},
+		{"Z3", 25},
+		{"AA1", 26},
+		{"AB12", 27},
+		{"AZ7", 51},
+		{"BA7", 52},
+		{"ZZ1", 701},
+		{"AAA1", 702},
+		{"A", 0},
+		{"12", -1},
+		{"", -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.reference, func(t *testing.T) {
+			if got := xlsxColumn(test.reference); got != test.want {
+				t.Errorf("xlsxColumn(%q) = %d, want %d", test.reference, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadTableUnsupported(t *testing.T) {
+	_, err := readTable("participants.xls", []byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("error = %v, want unsupported file type", err)
+	}
+}
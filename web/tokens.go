@@ -0,0 +1,53 @@
+package web
+
+import (
+	"contest-registration-bot/storage"
+	"github.com/flosch/pongo2/v4"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+)
+
+// tokensGet API tokens of current user
+func tokensGet(c echo.Context) error {
+	return renderTokens(c, "")
+}
+
+// tokenCreate Create API token, its secret is shown only once
+func tokenCreate(c echo.Context) error {
+	user := currentUser(c)
+
+	token, secret, err := storage.CreateAPIToken(user.Id, c.FormValue("name"))
+	if err != nil {
+		return err
+	}
+	log.Infof("API token %d created by %s", token.Id, user.Login)
+
+	return renderTokens(c, secret)
+}
+
+// tokenDelete Revoke API token of current user
+func tokenDelete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("token_id"), 10, 64)
+	if err != nil {
+		return err
+	}
+	if err := storage.DeleteUserAPIToken(currentUser(c).Id, id); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, "/tokens")
+}
+
+func renderTokens(c echo.Context, secret string) error {
+	tokens, err := storage.GetUserAPITokens(currentUser(c).Id)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "templates/tokens.twig", pongo2.Context{
+		"tokens":     tokens,
+		"secret":     secret,
+		"api_prefix": apiPrefix,
+	})
+}
@@ -0,0 +1,229 @@
+package web
+
+import (
+	"contest-registration-bot/storage"
+	"contest-registration-bot/webhooks"
+	"errors"
+	"fmt"
+	"github.com/flosch/pongo2/v4"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// webhookDeliveriesLimit Number of latest deliveries shown in the log
+const webhookDeliveriesLimit = 100
+
+type webhookRequest struct {
+	Id        uint64   `form:"webhook_id"`
+	ContestId uint64   `form:"contest_id"`
+	URL       string   `form:"url"`
+	Secret    string   `form:"secret"`
+	Events    []string `form:"events"`
+	Enabled   bool     `form:"enabled"`
+}
+
+type webhookIdRequest struct {
+	WebhookId  uint64 `param:"webhook_id" query:"webhook_id"`
+	DeliveryId uint64 `param:"delivery_id"`
+}
+
+type webhookView struct {
+	storage.Webhook
+	ContestName string
+}
+
+type webhookDeliveryView struct {
+	storage.WebhookDelivery
+	URL string
+}
+
+// PayloadText Delivery payload to show in the log
+func (delivery webhookDeliveryView) PayloadText() string {
+	return string(delivery.Payload)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// webhooksGet List webhooks and log of latest deliveries
+func webhooksGet(c echo.Context) error {
+	var id webhookIdRequest
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &id); err != nil {
+		return err
+	}
+
+	contests, err := contestNames()
+	if err != nil {
+		return err
+	}
+
+	list, err := storage.GetWebhooks()
+	if err != nil {
+		return err
+	}
+	urls := make(map[uint64]string)
+	var views []webhookView
+	for _, webhook := range list {
+		urls[webhook.Id] = webhook.URL
+		views = append(views, webhookView{Webhook: webhook, ContestName: contests[webhook.ContestId]})
+	}
+
+	deliveries, err := storage.GetWebhookDeliveries(id.WebhookId, webhookDeliveriesLimit)
+	if err != nil {
+		return err
+	}
+	var deliveryViews []webhookDeliveryView
+	for _, delivery := range deliveries {
+		deliveryViews = append(deliveryViews, webhookDeliveryView{WebhookDelivery: delivery, URL: urls[delivery.WebhookId]})
+	}
+
+	return c.Render(http.StatusOK, "templates/webhooks.twig", pongo2.Context{
+		"webhooks":   views,
+		"deliveries": deliveryViews,
+		"webhook_id": id.WebhookId,
+		"limit":      webhookDeliveriesLimit,
+	})
+}
+
+// webhookNew New webhook form
+func webhookNew(c echo.Context) error {
+	return renderWebhook(c, &storage.Webhook{
+		Enabled: true,
+		Events:  storage.WebhookEvents(),
+	})
+}
+
+// webhookEdit Edit webhook form
+func webhookEdit(c echo.Context) error {
+	webhook, err := webhookParam(c)
+	if err != nil {
+		return err
+	}
+	return renderWebhook(c, webhook)
+}
+
+// webhookSave Create new or update existing webhook
+func webhookSave(c echo.Context) error {
+	var webhookData webhookRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &webhookData); err != nil {
+		return err
+	}
+
+	webhook := &storage.Webhook{}
+	if webhookData.Id != 0 {
+		var err error
+		webhook, err = storage.GetWebhook(webhookData.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if webhookData.ContestId != 0 {
+		if _, err := storage.GetContest(webhookData.ContestId); err != nil {
+			return errors.New("contest not found")
+		}
+	}
+
+	webhook.ContestId = webhookData.ContestId
+	webhook.URL = strings.TrimSpace(webhookData.URL)
+	webhook.Events = webhookData.Events
+	webhook.Enabled = webhookData.Enabled
+	//empty secret keeps the current one, new webhook gets generated secret
+	if secret := strings.TrimSpace(webhookData.Secret); len(secret) != 0 {
+		webhook.Secret = secret
+	}
+
+	if err := storage.SaveWebhook(webhook); err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, fmt.Sprintf("/webhook/%d", webhook.Id))
+}
+
+// webhookDelete Delete webhook with its deliveries
+func webhookDelete(c echo.Context) error {
+	webhook, err := webhookParam(c)
+	if err != nil {
+		return err
+	}
+	if err := storage.DeleteWebhook(webhook.Id); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, "/webhooks")
+}
+
+// webhookPing Send test event to webhook
+func webhookPing(c echo.Context) error {
+	webhook, err := webhookParam(c)
+	if err != nil {
+		return err
+	}
+	if _, err := webhooks.Ping(webhook); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, fmt.Sprintf("/webhooks?webhook_id=%d", webhook.Id))
+}
+
+// webhookDeliveryRetry Send failed delivery again or retry waiting delivery right away
+func webhookDeliveryRetry(c echo.Context) error {
+	var id webhookIdRequest
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, &id); err != nil {
+		return err
+	}
+
+	delivery, err := storage.GetWebhookDelivery(id.DeliveryId)
+	if err != nil {
+		return err
+	}
+	if delivery.WebhookId != id.WebhookId {
+		return errors.New("delivery does not belong to webhook")
+	}
+	if delivery.Status == storage.DeliveryStatusPending && delivery.Attempts == 0 {
+		return errors.New("delivery is already queued")
+	}
+
+	if err := webhooks.Retry(delivery); err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, fmt.Sprintf("/webhooks?webhook_id=%d", id.WebhookId))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func renderWebhook(c echo.Context, webhook *storage.Webhook) error {
+	contests, err := storage.GetContests()
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "templates/webhook.twig", pongo2.Context{
+		"webhook":          webhook,
+		"contests":         contests,
+		"events":           storage.WebhookEvents(),
+		"signature_header": webhooks.SignatureHeader,
+		"event_header":     webhooks.EventHeader,
+		"delivery_header":  webhooks.DeliveryHeader,
+	})
+}
+
+func webhookParam(c echo.Context) (*storage.Webhook, error) {
+	var id webhookIdRequest
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, &id); err != nil {
+		return nil, err
+	}
+	return storage.GetWebhook(id.WebhookId)
+}
+
+// contestNames Contest names by id
+func contestNames() (map[uint64]string, error) {
+	contests, err := storage.GetContests()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint64]string)
+	for _, contest := range contests {
+		names[contest.Id] = contest.Name
+	}
+	return names, nil
+}
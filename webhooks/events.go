@@ -0,0 +1,200 @@
+package webhooks
+
+import (
+	"contest-registration-bot/storage"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Payload JSON body of webhook request
+type Payload struct {
+	Event        string               `json:"event"`
+	CreatedAt    time.Time            `json:"created_at"`
+	Contest      *ContestPayload      `json:"contest,omitempty"`
+	Participant  *ParticipantPayload  `json:"participant,omitempty"`
+	Notification *NotificationPayload `json:"notification,omitempty"`
+}
+
+type ContestPayload struct {
+	Id                   uint64     `json:"id"`
+	Name                 string     `json:"name"`
+	Closed               bool       `json:"closed"`
+	Hidden               bool       `json:"hidden"`
+	Capacity             int        `json:"capacity"`
+	StartsAt             *time.Time `json:"starts_at"`
+	RegistrationDeadline *time.Time `json:"registration_deadline"`
+}
+
+// ParticipantPayload Participant data, password is never sent
+type ParticipantPayload struct {
+	Id         uint64            `json:"id"`
+	ContestId  uint64            `json:"contest_id"`
+	TelegramId int64             `json:"telegram_id"`
+	Name       string            `json:"name"`
+	Answers    map[string]string `json:"answers"`
+	Login      string            `json:"login"`
+	Room       string            `json:"room"`
+	Seat       string            `json:"seat"`
+	Waitlisted bool              `json:"waitlisted"`
+	Members    []MemberPayload   `json:"members,omitempty"`
+	// Phone and Telegram profile collected in the bot
+	Phone            string `json:"phone,omitempty"`
+	TelegramUsername string `json:"telegram_username,omitempty"`
+	FirstName        string `json:"first_name,omitempty"`
+	LastName         string `json:"last_name,omitempty"`
+}
+
+// MemberPayload Team member in team mode contests
+type MemberPayload struct {
+	TelegramId int64  `json:"telegram_id"`
+	Name       string `json:"name"`
+}
+
+type NotificationPayload struct {
+	Id        uint64 `json:"id"`
+	ContestId uint64 `json:"contest_id"`
+	Message   string `json:"message"`
+	Reminder  bool   `json:"reminder"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// ParticipantCreated Queue participant.created event
+func ParticipantCreated(participant *storage.ContestParticipant) {
+	participantEvent(storage.WebhookEventParticipantCreated, participant)
+}
+
+// ParticipantUpdated Queue participant.updated event
+func ParticipantUpdated(participant *storage.ContestParticipant) {
+	participantEvent(storage.WebhookEventParticipantUpdated, participant)
+}
+
+// ParticipantDeleted Queue participant.deleted event
+func ParticipantDeleted(participant *storage.ContestParticipant) {
+	participantEvent(storage.WebhookEventParticipantDeleted, participant)
+}
+
+// ContestOpened Queue contest.opened event
+func ContestOpened(contest *storage.Contest) {
+	fire(contest.Id, &Payload{
+		Event:   storage.WebhookEventContestOpened,
+		Contest: newContestPayload(contest),
+	})
+}
+
+// ContestClosed Queue contest.closed event
+func ContestClosed(contest *storage.Contest) {
+	fire(contest.Id, &Payload{
+		Event:   storage.WebhookEventContestClosed,
+		Contest: newContestPayload(contest),
+	})
+}
+
+// NotificationSent Queue notification.sent event, message is the notification text in default language
+func NotificationSent(notification *storage.ContestNotification, message string) {
+	payload := &Payload{
+		Event: storage.WebhookEventNotificationSent,
+		Notification: &NotificationPayload{
+			Id:        notification.Id,
+			ContestId: notification.ContestId,
+			Message:   message,
+			Reminder:  notification.IsReminder(),
+		},
+	}
+	if contest, err := storage.GetContest(notification.ContestId); err == nil {
+		payload.Contest = newContestPayload(contest)
+	}
+	fire(notification.ContestId, payload)
+}
+
+// Ping Queue test event to given webhook
+func Ping(webhook *storage.Webhook) (*storage.WebhookDelivery, error) {
+	data, err := json.Marshal(&Payload{
+		Event:     storage.WebhookEventPing,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := storage.CreateWebhookDelivery(webhook.Id, storage.WebhookEventPing, data)
+	if err != nil {
+		return nil, err
+	}
+	wakeUp()
+
+	return delivery, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func participantEvent(event string, participant *storage.ContestParticipant) {
+	payload := &Payload{
+		Event: event,
+		Participant: &ParticipantPayload{
+			Id:         participant.Id,
+			ContestId:  participant.ContestId,
+			TelegramId: participant.ParticipantId,
+			Name:       participant.Name,
+			Answers:    participant.Answers,
+			Login:      participant.Login,
+			Room:       participant.Room,
+			Seat:       participant.Seat,
+			Waitlisted: participant.Waitlisted,
+
+			Phone:            participant.Phone,
+			TelegramUsername: participant.TelegramUsername,
+			FirstName:        participant.FirstName,
+			LastName:         participant.LastName,
+		},
+	}
+	for _, member := range participant.Members {
+		payload.Participant.Members = append(payload.Participant.Members, MemberPayload{
+			TelegramId: member.ChatId,
+			Name:       member.Name,
+		})
+	}
+	if contest, err := storage.GetContest(participant.ContestId); err == nil {
+		payload.Contest = newContestPayload(contest)
+	}
+	fire(participant.ContestId, payload)
+}
+
+// fire Queue event to subscribed webhooks, errors are logged
+// and do not affect the action which caused the event
+func fire(contestId uint64, payload *Payload) {
+	payload.CreatedAt = time.Now()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("webhooks: unable to encode %s event: %s", payload.Event, err)
+		return
+	}
+
+	deliveries, err := storage.CreateWebhookDeliveries(contestId, payload.Event, data)
+	if err != nil {
+		log.Errorf("webhooks: unable to queue %s event: %s", payload.Event, err)
+		return
+	}
+	if len(deliveries) != 0 {
+		wakeUp()
+	}
+}
+
+func newContestPayload(contest *storage.Contest) *ContestPayload {
+	payload := &ContestPayload{
+		Id:       contest.Id,
+		Name:     contest.Name,
+		Closed:   contest.Closed,
+		Hidden:   contest.Hidden,
+		Capacity: contest.Capacity,
+	}
+	if !contest.StartsAt.IsZero() {
+		payload.StartsAt = &contest.StartsAt
+	}
+	if !contest.RegistrationDeadline.IsZero() {
+		payload.RegistrationDeadline = &contest.RegistrationDeadline
+	}
+	return payload
+}
@@ -0,0 +1,229 @@
+package webhooks
+
+import (
+	"bytes"
+	"contest-registration-bot/storage"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/timshannon/bolthold"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	defaultTimeout  = 10 * time.Second
+	defaultKeepLog  = 30 * 24 * time.Hour
+
+	cleanupInterval = time.Hour
+
+	// maxResponseLength Response body length saved in the delivery log
+	maxResponseLength = 500
+
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// retryDelays Delays before repeated attempts, delivery fails after the last one
+var retryDelays = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+}
+
+// kick Wakes dispatcher up when new deliveries are queued
+var kick = make(chan struct{}, 1)
+
+type Configuration struct {
+	// Interval How often to check the retry queue
+	Interval time.Duration
+	// Timeout HTTP request timeout
+	Timeout time.Duration
+	// KeepLog How long to keep finished deliveries
+	KeepLog time.Duration
+}
+
+type Dispatcher struct {
+	config      Configuration
+	client      *http.Client
+	stop        chan struct{}
+	done        chan struct{}
+	lastCleanup time.Time
+}
+
+// New Create new dispatcher of queued webhook deliveries
+func New(config Configuration) *Dispatcher {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.KeepLog <= 0 {
+		config.KeepLog = defaultKeepLog
+	}
+	return &Dispatcher{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start Deliver queued events in background
+func (dispatcher *Dispatcher) Start() {
+	go func() {
+		defer close(dispatcher.done)
+
+		ticker := time.NewTicker(dispatcher.config.Interval)
+		defer ticker.Stop()
+
+		dispatcher.run()
+
+		for {
+			select {
+			case <-dispatcher.stop:
+				return
+			case <-ticker.C:
+				dispatcher.run()
+			case <-kick:
+				dispatcher.run()
+			}
+		}
+	}()
+}
+
+// Stop Wait for current deliveries and stop dispatcher
+func (dispatcher *Dispatcher) Stop() {
+	close(dispatcher.stop)
+	<-dispatcher.done
+}
+
+func (dispatcher *Dispatcher) run() {
+	now := time.Now()
+
+	deliveries, err := storage.GetDueWebhookDeliveries(now)
+	if err != nil {
+		log.Errorf("webhooks: unable to get due deliveries: %s", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		select {
+		case <-dispatcher.stop:
+			return
+		default:
+		}
+		dispatcher.deliver(&delivery)
+	}
+
+	if now.Sub(dispatcher.lastCleanup) >= cleanupInterval {
+		dispatcher.lastCleanup = now
+		if err := storage.DeleteFinishedWebhookDeliveries(now.Add(-dispatcher.config.KeepLog)); err != nil {
+			log.Errorf("webhooks: unable to delete old deliveries: %s", err)
+		}
+	}
+}
+
+// deliver Send delivery payload and schedule retry on failure
+func (dispatcher *Dispatcher) deliver(delivery *storage.WebhookDelivery) {
+	webhook, err := storage.GetWebhook(delivery.WebhookId)
+	if err != nil && err != bolthold.ErrNotFound {
+		log.Errorf("webhooks: unable to get webhook %d: %s", delivery.WebhookId, err)
+		return
+	}
+
+	delivery.Attempts++
+
+	switch {
+	case webhook == nil:
+		delivery.Status = storage.DeliveryStatusFailed
+		delivery.Error = "webhook deleted"
+	case !webhook.Enabled && delivery.Event != storage.WebhookEventPing:
+		delivery.Status = storage.DeliveryStatusFailed
+		delivery.Error = "webhook disabled"
+	default:
+		code, err := dispatcher.post(webhook, delivery)
+		delivery.ResponseCode = code
+		if err == nil {
+			delivery.Status = storage.DeliveryStatusSent
+			delivery.Error = ""
+		} else if delivery.Attempts > len(retryDelays) {
+			delivery.Status = storage.DeliveryStatusFailed
+			delivery.Error = err.Error()
+		} else {
+			delivery.Error = err.Error()
+			delivery.NextAttemptAt = time.Now().Add(retryDelays[delivery.Attempts-1])
+		}
+	}
+
+	if delivery.Status == storage.DeliveryStatusFailed {
+		log.Warnf("webhooks: delivery %d of %s to webhook %d failed: %s", delivery.Id, delivery.Event, delivery.WebhookId, delivery.Error)
+	}
+
+	if err := storage.SaveWebhookDelivery(delivery); err != nil {
+		log.Errorf("webhooks: unable to save delivery %d: %s", delivery.Id, err)
+	}
+}
+
+// Retry Queue delivery again, next attempt is made right away
+func Retry(delivery *storage.WebhookDelivery) error {
+	delivery.Status = storage.DeliveryStatusPending
+	delivery.NextAttemptAt = time.Now()
+	if err := storage.SaveWebhookDelivery(delivery); err != nil {
+		return err
+	}
+	wakeUp()
+	return nil
+}
+
+// post Send signed payload, any 2xx response is success
+func (dispatcher *Dispatcher) post(webhook *storage.Webhook, delivery *storage.WebhookDelivery) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "contest-registration-bot")
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, strconv.FormatUint(delivery.Id, 10))
+	request.Header.Set(SignatureHeader, Signature(webhook.Secret, delivery.Payload))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseLength))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("HTTP %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return response.StatusCode, nil
+}
+
+// Signature HMAC-SHA256 of the payload with webhook secret, "sha256=<hex>"
+func Signature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// wakeUp Ask dispatcher to deliver queued events now
+func wakeUp() {
+	select {
+	case kick <- struct{}{}:
+	default:
+	}
+}
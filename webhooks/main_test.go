@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"contest-registration-bot/storage"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// receivedRequest Request captured by the local webhook receiver
+type receivedRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+// testReceiver Local HTTP stand-in of the webhook receiver answering with given status
+type testReceiver struct {
+	server   *httptest.Server
+	mutex    sync.Mutex
+	status   int
+	requests []receivedRequest
+}
+
+func newTestReceiver(t *testing.T, status int) *testReceiver {
+	receiver := &testReceiver{status: status}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mutex.Lock()
+		receiver.requests = append(receiver.requests, receivedRequest{Header: r.Header.Clone(), Body: body})
+		receiver.mutex.Unlock()
+		w.WriteHeader(receiver.status)
+		_, _ = w.Write([]byte("receiver response"))
+	}))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+func (receiver *testReceiver) received() []receivedRequest {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return append([]receivedRequest(nil), receiver.requests...)
+}
+
+// setupDelivery Open empty storage and queue one event to the webhook of the receiver
+func setupDelivery(t *testing.T, receiver *testReceiver) *storage.WebhookDelivery {
+	t.Helper()
+
+	if err := storage.Open(filepath.Join(t.TempDir(), "bolt.db")); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+
+	webhook := &storage.Webhook{
+		URL:     receiver.server.URL + "/hook",
+		Secret:  testSecret,
+		Events:  []string{storage.WebhookEventParticipantCreated},
+		Enabled: true,
+	}
+	if err := storage.SaveWebhook(webhook); err != nil {
+		t.Fatalf("unable to save webhook: %s", err)
+	}
+
+	deliveries, err := storage.CreateWebhookDeliveries(1, storage.WebhookEventParticipantCreated, []byte(`{"event":"participant.created"}`))
+	if err != nil {
+		t.Fatalf("unable to queue delivery: %s", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("queued %d deliveries, want 1", len(deliveries))
+	}
+	return &deliveries[0]
+}
+
+func getDelivery(t *testing.T, id uint64) *storage.WebhookDelivery {
+	t.Helper()
+	delivery, err := storage.GetWebhookDelivery(id)
+	if err != nil {
+		t.Fatalf("unable to get delivery %d: %s", id, err)
+	}
+	return delivery
+}
+
+func TestSignature(t *testing.T) {
+	payload := []byte(`{"event":"ping"}`)
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write(payload)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Signature(testSecret, payload); got != want {
+		t.Errorf("Signature() = %s, want %s", got, want)
+	}
+	if Signature("other-secret", payload) == want {
+		t.Error("signature does not depend on secret")
+	}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusNoContent)
+	delivery := setupDelivery(t, receiver)
+
+	New(Configuration{}).run()
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	request := requests[0]
+	if string(request.Body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", request.Body, delivery.Payload)
+	}
+	if got, want := request.Header.Get(SignatureHeader), Signature(testSecret, request.Body); got != want {
+		t.Errorf("%s = %s, want %s", SignatureHeader, got, want)
+	}
+	if got := request.Header.Get(EventHeader); got != storage.WebhookEventParticipantCreated {
+		t.Errorf("%s = %s, want %s", EventHeader, got, storage.WebhookEventParticipantCreated)
+	}
+	if got := request.Header.Get(DeliveryHeader); got != strconv.FormatUint(delivery.Id, 10) {
+		t.Errorf("%s = %s, want %d", DeliveryHeader, got, delivery.Id)
+	}
+
+	saved := getDelivery(t, delivery.Id)
+	if saved.Status != storage.DeliveryStatusSent || saved.Attempts != 1 || saved.ResponseCode != http.StatusNoContent {
+		t.Errorf("delivery status = %s, attempts = %d, code = %d, want sent after 1 attempt with 204",
+			saved.Status, saved.Attempts, saved.ResponseCode)
+	}
+}
+
+func TestDeliverRetry(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusServiceUnavailable)
+	delivery := setupDelivery(t, receiver)
+	dispatcher := New(Configuration{})
+
+	for attempt := 1; attempt <= len(retryDelays); attempt++ {
+		before := time.Now()
+		dispatcher.deliver(getDelivery(t, delivery.Id))
+
+		saved := getDelivery(t, delivery.Id)
+		if saved.Status != storage.DeliveryStatusPending {
+			t.Fatalf("attempt %d: status = %s, want %s", attempt, saved.Status, storage.DeliveryStatusPending)
+		}
+		if saved.Attempts != attempt {
+			t.Errorf("attempt %d: attempts = %d", attempt, saved.Attempts)
+		}
+		if saved.ResponseCode != http.StatusServiceUnavailable || !strings.Contains(saved.Error, "HTTP 503") {
+			t.Errorf("attempt %d: code = %d, error = %q, want HTTP 503", attempt, saved.ResponseCode, saved.Error)
+		}
+		delay := retryDelays[attempt-1]
+		if saved.NextAttemptAt.Before(before.Add(delay)) || saved.NextAttemptAt.After(time.Now().Add(delay)) {
+			t.Errorf("attempt %d: next attempt in %s, want %s", attempt, saved.NextAttemptAt.Sub(before), delay)
+		}
+	}
+
+	//retry is not due yet
+	dispatcher.run()
+	if got := len(receiver.received()); got != len(retryDelays) {
+		t.Errorf("receiver got %d requests before retry time, want %d", got, len(retryDelays))
+	}
+
+	//last attempt after all retry delays fails the delivery
+	dispatcher.deliver(getDelivery(t, delivery.Id))
+	saved := getDelivery(t, delivery.Id)
+	if saved.Status != storage.DeliveryStatusFailed {
+		t.Errorf("status = %s after %d attempts, want %s", saved.Status, saved.Attempts, storage.DeliveryStatusFailed)
+	}
+	if saved.Attempts != len(retryDelays)+1 {
+		t.Errorf("attempts = %d, want %d", saved.Attempts, len(retryDelays)+1)
+	}
+	if got := len(receiver.received()); got != len(retryDelays)+1 {
+		t.Errorf("receiver got %d requests, want %d", got, len(retryDelays)+1)
+	}
+}
+
+func TestRetryFailedDelivery(t *testing.T) {
+	receiver := newTestReceiver(t, http.StatusInternalServerError)
+	delivery := setupDelivery(t, receiver)
+	dispatcher := New(Configuration{})
+
+	failed := getDelivery(t, delivery.Id)
+	failed.Attempts = len(retryDelays)
+	dispatcher.deliver(failed)
+	if saved := getDelivery(t, delivery.Id); saved.Status != storage.DeliveryStatusFailed {
+		t.Fatalf("status = %s, want %s", saved.Status, storage.DeliveryStatusFailed)
+	}
+
+	receiver.mutex.Lock()
+	receiver.status = http.StatusOK
+	receiver.mutex.Unlock()
+
+	if err := Retry(getDelivery(t, delivery.Id)); err != nil {
+		t.Fatalf("unable to retry: %s", err)
+	}
+	dispatcher.run()
+
+	if saved := getDelivery(t, delivery.Id); saved.Status != storage.DeliveryStatusSent {
+		t.Errorf("status after manual retry = %s, want %s", saved.Status, storage.DeliveryStatusSent)
+	}
+}